@@ -1,17 +1,30 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"log"
 	"net"
 	"net/http"
+	"os"
 
 	"github.com/joho/godotenv"
 	"google.golang.org/grpc"
 
 	pb "authentication/src/gen/proto"
-	"authentication/src/platform/authenticator"
+	"authentication/src/platform/apikey"
+	"authentication/src/platform/authz"
+	"authentication/src/platform/bff"
+	"authentication/src/platform/clientauth"
+	"authentication/src/platform/directory"
 	grpcServer "authentication/src/platform/grpc"
+	"authentication/src/platform/redirect"
+	"authentication/src/platform/revocation"
 	"authentication/src/platform/router"
+	"authentication/src/platform/service"
+	"authentication/src/platform/session"
+	"authentication/src/platform/state"
+	"authentication/src/platform/tenant"
 )
 
 func main() {
@@ -19,9 +32,65 @@ func main() {
 		log.Fatalf("Failed to load the env vars: %v", err)
 	}
 
-	auth, err := authenticator.New()
+	states := state.NewStore(state.DefaultTTL)
+	states.Start(context.Background())
+	cookie, err := state.NewCookie(state.DefaultTTL)
 	if err != nil {
-		log.Fatalf("Failed to initialize the authenticator: %v", err)
+		log.Fatalf("Failed to initialize the state cookie: %v", err)
+	}
+
+	redirects, err := redirect.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load the redirect allow-list: %v", err)
+	}
+
+	policy, err := authz.Load()
+	if err != nil {
+		log.Fatalf("Failed to load the authorization policy: %v", err)
+	}
+
+	clients, err := clientauth.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load the introspection clients: %v", err)
+	}
+
+	services, err := service.Load()
+	if err != nil {
+		log.Fatalf("Failed to load the internal services: %v", err)
+	}
+
+	tenants, err := tenant.Load(context.Background(), redirects)
+	if err != nil {
+		log.Fatalf("Failed to initialize the tenants: %v", err)
+	}
+
+	revocations, err := revocation.FromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize the revocation store: %v", err)
+	}
+	tenants.SetRevocations(revocations)
+
+	keyStore, err := apikey.FromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize the API key store: %v", err)
+	}
+	apiKeys := apikey.New(keyStore)
+	tenants.SetAPIKeys(apiKeys)
+
+	userStore, err := directory.FromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize the user directory: %v", err)
+	}
+	users := directory.New(userStore)
+
+	sessions, err := session.FromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize the session store: %v", err)
+	}
+
+	web, err := bff.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load the web configuration: %v", err)
 	}
 
 	// Start gRPC server in a goroutine
@@ -32,7 +101,9 @@ func main() {
 		}
 
 		s := grpc.NewServer()
-		pb.RegisterAuthServiceServer(s, grpcServer.NewServer(auth))
+		auth := grpcServer.NewServer(tenants, states, redirects, policy, services, apiKeys, users)
+		pb.RegisterAuthServiceServer(s, auth)
+		pb.RegisterAdminServiceServer(s, grpcServer.NewAdminServer(auth))
 
 		log.Printf("gRPC server listening on :50051")
 		if err := s.Serve(lis); err != nil {
@@ -40,8 +111,23 @@ func main() {
 		}
 	}()
 
+	// Metrics, e.g. JWKS refresh failures, on a listener that is not exposed
+	go func() {
+		addr := os.Getenv("METRICS_ADDR")
+		if addr == "" {
+			addr = "127.0.0.1:9090"
+		}
+		metrics := http.NewServeMux()
+		metrics.Handle("/debug/vars", expvar.Handler())
+
+		log.Printf("Metrics listening on http://%s/debug/vars", addr)
+		if err := http.ListenAndServe(addr, metrics); err != nil {
+			log.Printf("metrics server stopped: %v", err)
+		}
+	}()
+
 	// Start HTTP server
-	rtr := router.New(auth)
+	rtr := router.New(tenants, states, cookie, redirects, clients, sessions, revocations, web, apiKeys, policy, users)
 	log.Print("HTTP server listening on http://localhost:3000/")
 	if err := http.ListenAndServe("0.0.0.0:3000", rtr); err != nil {
 		log.Fatalf("There was an error with the http server: %v", err)
@@ -0,0 +1,386 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"authentication/src/platform/authenticator"
+	"authentication/src/platform/identity"
+)
+
+// Prefix starts every API key and PersonalPrefix every personal access
+// token, so both are recognisable in logs and by secret scanners.
+const (
+	Prefix         = "dtk_"
+	PersonalPrefix = "dtp_"
+)
+
+const (
+	// MaxPersonalLifetime caps how long personal access tokens are valid.
+	MaxPersonalLifetime = 365 * 24 * time.Hour
+	// touchInterval limits how often LastUsedAt is written back
+	touchInterval = time.Minute
+)
+
+var (
+	ErrInvalid  = errors.New("invalid API key")
+	ErrNotFound = errors.New("API key not found")
+)
+
+// Key is an API key without its secret.
+type Key struct {
+	ID       string
+	TenantID string
+	Name     string
+	// CreatedBy is the subject of whoever created the key.
+	CreatedBy string
+	// Owner makes the key a personal access token acting as this subject.
+	Owner     string
+	Scopes    []string
+	CreatedAt time.Time
+	// RotatedAt is when the current secret was issued.
+	RotatedAt  time.Time
+	ExpiresAt  time.Time
+	RevokedAt  time.Time
+	LastUsedAt time.Time
+}
+
+// Personal reports whether the key is a personal access token.
+func (k *Key) Personal() bool {
+	return k.Owner != ""
+}
+
+// Display is how the key is shown in lists: the prefix and the ID, never
+// the secret.
+func (k *Key) Display() string {
+	return k.prefix() + k.ID
+}
+
+func (k *Key) prefix() string {
+	if k.Personal() {
+		return PersonalPrefix
+	}
+	return Prefix
+}
+
+// Record is a stored key with the salted hash of its secret.
+type Record struct {
+	Key
+	Salt []byte
+	Hash []byte
+}
+
+// Store keeps API keys.
+type Store interface {
+	Create(ctx context.Context, r *Record) error
+	// Get returns the key with id, or ErrNotFound.
+	Get(ctx context.Context, id string) (*Record, error)
+	// List returns the tenant's keys, newest first.
+	List(ctx context.Context, tenantID string) ([]*Key, error)
+	// Update replaces the key with the same ID.
+	Update(ctx context.Context, r *Record) error
+	// Touch records that the key with id was used at t.
+	Touch(ctx context.Context, id string, t time.Time) error
+}
+
+// FromEnv returns a Postgres backed Store when DATABASE_URL is set and an
+// in-memory one otherwise.
+func FromEnv(ctx context.Context) (Store, error) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		return NewMemory(), nil
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgres(ctx, db)
+}
+
+// Keys issues and verifies API keys.
+type Keys struct {
+	store Store
+	now   func() time.Time
+}
+
+// New instantiates *Keys backed by store.
+func New(store Store) *Keys {
+	return &Keys{store: store, now: time.Now}
+}
+
+// Create issues a key and returns it with the full secret, which is not
+// stored and cannot be shown again.
+func (k *Keys) Create(ctx context.Context, key Key) (*Key, string, error) {
+	id, err := random(8)
+	if err != nil {
+		return nil, "", err
+	}
+	key.ID = hex.EncodeToString(id)
+	key.CreatedAt = k.now()
+	key.RotatedAt = key.CreatedAt
+	key.RevokedAt = time.Time{}
+
+	record, secret, err := seal(key)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := k.store.Create(ctx, record); err != nil {
+		return nil, "", err
+	}
+	return &record.Key, secret, nil
+}
+
+// CreatePersonal issues a personal access token for key.Owner. It has to
+// expire within MaxPersonalLifetime.
+func (k *Keys) CreatePersonal(ctx context.Context, key Key) (*Key, string, error) {
+	if key.Owner == "" {
+		return nil, "", errors.New("personal access tokens need an owner")
+	}
+	if key.ExpiresAt.IsZero() || key.ExpiresAt.After(k.now().Add(MaxPersonalLifetime)) {
+		return nil, "", fmt.Errorf("personal access tokens have to expire within %s", MaxPersonalLifetime)
+	}
+	if !key.ExpiresAt.After(k.now()) {
+		return nil, "", errors.New("expiry is in the past")
+	}
+	return k.Create(ctx, key)
+}
+
+// List returns the tenant's API keys, without personal access tokens.
+func (k *Keys) List(ctx context.Context, tenantID string) ([]*Key, error) {
+	return k.list(ctx, tenantID, func(key *Key) bool { return !key.Personal() })
+}
+
+// ListPersonal returns the personal access tokens of owner.
+func (k *Keys) ListPersonal(ctx context.Context, tenantID, owner string) ([]*Key, error) {
+	return k.list(ctx, tenantID, func(key *Key) bool { return key.Owner == owner })
+}
+
+func (k *Keys) list(ctx context.Context, tenantID string, keep func(*Key) bool) ([]*Key, error) {
+	all, err := k.store.List(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*Key, 0, len(all))
+	for _, key := range all {
+		if keep(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Revoke disables the tenant's API key with id for good.
+func (k *Keys) Revoke(ctx context.Context, tenantID, id string) (*Key, error) {
+	return k.revoke(ctx, tenantID, "", id)
+}
+
+// RevokePersonal disables a personal access token of owner for good.
+func (k *Keys) RevokePersonal(ctx context.Context, tenantID, owner, id string) (*Key, error) {
+	return k.revoke(ctx, tenantID, owner, id)
+}
+
+func (k *Keys) revoke(ctx context.Context, tenantID, owner, id string) (*Key, error) {
+	record, err := k.get(ctx, tenantID, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if record.RevokedAt.IsZero() {
+		record.RevokedAt = k.now()
+		if err := k.store.Update(ctx, record); err != nil {
+			return nil, err
+		}
+	}
+	return &record.Key, nil
+}
+
+// Rotate replaces the secret of the tenant's API key with id. The old
+// secret stops working immediately.
+func (k *Keys) Rotate(ctx context.Context, tenantID, id string) (*Key, string, error) {
+	record, err := k.get(ctx, tenantID, "", id)
+	if err != nil {
+		return nil, "", err
+	}
+	if !record.RevokedAt.IsZero() {
+		return nil, "", ErrNotFound
+	}
+
+	record.Key.RotatedAt = k.now()
+	rotated, secret, err := seal(record.Key)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := k.store.Update(ctx, rotated); err != nil {
+		return nil, "", err
+	}
+	return &rotated.Key, secret, nil
+}
+
+// Verify checks a raw key for tenantID and returns its principal. Scopes of
+// the key double as its permissions. Personal access tokens act as their
+// owner.
+func (k *Keys) Verify(ctx context.Context, raw, tenantID string) (*identity.Principal, error) {
+	record, err := k.check(ctx, raw, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Last use is informational, a failed write must not reject the key
+	now := k.now()
+	if now.Sub(record.LastUsedAt) > touchInterval {
+		if err := k.store.Touch(ctx, record.ID, now); err != nil {
+			log.Printf("failed to record use of API key %s: %v", record.ID, err)
+		}
+	}
+
+	principal := &identity.Principal{
+		Subject:     "apikey|" + record.ID,
+		TokenID:     record.ID,
+		TenantID:    record.TenantID,
+		Type:        identity.TypeAPIKey,
+		Scopes:      record.Scopes,
+		Permissions: record.Scopes,
+		Kind:        authenticator.KindAPIKey,
+		IssuedAt:    record.RotatedAt,
+		ExpiresAt:   record.ExpiresAt,
+		Claims: map[string]interface{}{
+			"name":       record.Name,
+			"created_by": record.CreatedBy,
+		},
+	}
+	if record.Personal() {
+		principal.Subject = record.Owner
+		principal.Type = identity.TypeUser
+		principal.Kind = authenticator.KindPersonalToken
+	}
+	return principal, nil
+}
+
+// RevokeRaw disables the key raw, as presented by its holder, e.g. through
+// token revocation (RFC 7009). Keys that do not verify are left alone, like
+// invalid tokens.
+func (k *Keys) RevokeRaw(ctx context.Context, raw, tenantID string) error {
+	record, err := k.check(ctx, raw, tenantID)
+	if errors.Is(err, ErrInvalid) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	record.RevokedAt = k.now()
+	return k.store.Update(ctx, record)
+}
+
+// check returns the record of the usable key raw. Keys that are unknown,
+// revoked or expired fail with ErrInvalid.
+func (k *Keys) check(ctx context.Context, raw, tenantID string) (*Record, error) {
+	prefix, id, secret, ok := parse(raw)
+	if !ok {
+		return nil, ErrInvalid
+	}
+
+	record, err := k.store.Get(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hash := hashSecret(record.Salt, secret)
+	if subtle.ConstantTimeCompare(hash, record.Hash) != 1 {
+		return nil, ErrInvalid
+	}
+
+	now := k.now()
+	switch {
+	case record.prefix() != prefix, record.TenantID != tenantID:
+		return nil, ErrInvalid
+	case !record.RevokedAt.IsZero():
+		return nil, fmt.Errorf("%w: it has been revoked", ErrInvalid)
+	case !record.ExpiresAt.IsZero() && !record.ExpiresAt.After(now):
+		return nil, fmt.Errorf("%w: it has expired", ErrInvalid)
+	}
+	return record, nil
+}
+
+// CanOwn reports whether p may hold personal access tokens: users signed in
+// with the identity provider, not callers using another key.
+func CanOwn(p *identity.Principal) bool {
+	return p.Type == identity.TypeUser &&
+		(p.Kind == authenticator.KindAccessToken || p.Kind == authenticator.KindIDToken)
+}
+
+// IsKey reports whether raw looks like an API key or personal access token
+// rather than a JWT.
+func IsKey(raw string) bool {
+	return strings.HasPrefix(raw, Prefix) || strings.HasPrefix(raw, PersonalPrefix)
+}
+
+// get returns the tenant's key with id. owner has to match, so API key RPCs
+// cannot touch personal access tokens and users only their own.
+func (k *Keys) get(ctx context.Context, tenantID, owner, id string) (*Record, error) {
+	record, err := k.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if record.TenantID != tenantID || record.Owner != owner {
+		return nil, ErrNotFound
+	}
+	return record, nil
+}
+
+// seal generates a secret for key and returns the record to store. Raw
+// keys look like dtk_<id>_<secret>, or dtp_<id>_<secret> when personal.
+func seal(key Key) (*Record, string, error) {
+	secret, err := random(32)
+	if err != nil {
+		return nil, "", err
+	}
+	salt, err := random(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	record := &Record{Key: key, Salt: salt, Hash: hashSecret(salt, encoded)}
+	return record, key.prefix() + key.ID + "_" + encoded, nil
+}
+
+func parse(raw string) (prefix, id, secret string, ok bool) {
+	for _, prefix := range []string{Prefix, PersonalPrefix} {
+		if rest, found := strings.CutPrefix(raw, prefix); found {
+			id, secret, ok = strings.Cut(rest, "_")
+			return prefix, id, secret, ok && id != "" && secret != ""
+		}
+	}
+	return "", "", "", false
+}
+
+// hashSecret hashes a secret with its salt. Secrets are random, so a single
+// round of SHA-256 is enough.
+func hashSecret(salt []byte, secret string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(secret))
+	return h.Sum(nil)
+}
+
+func random(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
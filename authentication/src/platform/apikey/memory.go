@@ -0,0 +1,88 @@
+package apikey
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Memory is a Store for a single replica.
+type Memory struct {
+	mu   sync.Mutex
+	keys map[string]Record
+}
+
+// NewMemory instantiates an empty *Memory.
+func NewMemory() *Memory {
+	return &Memory{keys: make(map[string]Record)}
+}
+
+func (m *Memory) Create(ctx context.Context, r *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keys[r.ID] = *r
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, id string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.keys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &r, nil
+}
+
+func (m *Memory) List(ctx context.Context, tenantID string) ([]*Key, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []*Key
+	for _, r := range m.keys {
+		if r.TenantID == tenantID {
+			key := r.Key
+			keys = append(keys, &key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b *Key) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return keys, nil
+}
+
+func (m *Memory) Touch(ctx context.Context, id string, t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.keys[id]; ok {
+		r.LastUsedAt = t
+		m.keys[id] = r
+	}
+	return nil
+}
+
+func (m *Memory) Update(ctx context.Context, r *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.keys[r.ID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	// Like the Postgres store only the mutable fields change, so a
+	// concurrent Touch is not lost
+	stored.Name = r.Name
+	stored.Scopes = r.Scopes
+	stored.Salt = r.Salt
+	stored.Hash = r.Hash
+	stored.RotatedAt = r.RotatedAt
+	stored.ExpiresAt = r.ExpiresAt
+	stored.RevokedAt = r.RevokedAt
+	m.keys[r.ID] = stored
+	return nil
+}
@@ -0,0 +1,125 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Postgres is a Store shared by all replicas.
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres instantiates a *Postgres and creates its table if needed.
+func NewPostgres(ctx context.Context, db *sql.DB) (*Postgres, error) {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS api_keys (
+		id         TEXT PRIMARY KEY,
+		tenant_id  TEXT NOT NULL,
+		name       TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		owner      TEXT NOT NULL DEFAULT '',
+		scopes     TEXT[] NOT NULL,
+		salt       BYTEA NOT NULL,
+		hash       BYTEA NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		rotated_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ,
+		revoked_at TIMESTAMPTZ,
+		last_used_at TIMESTAMPTZ
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	return &Postgres{db: db}, nil
+}
+
+const columns = `id, tenant_id, name, created_by, owner, scopes, salt, hash, created_at, rotated_at, expires_at, revoked_at, last_used_at`
+
+func (p *Postgres) Create(ctx context.Context, r *Record) error {
+	_, err := p.db.ExecContext(ctx, `INSERT INTO api_keys (`+columns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		r.ID, r.TenantID, r.Name, r.CreatedBy, r.Owner, textArray(r.Scopes), r.Salt, r.Hash,
+		r.CreatedAt, r.RotatedAt, nullTime(r.ExpiresAt), nullTime(r.RevokedAt), nullTime(r.LastUsedAt))
+	return err
+}
+
+func (p *Postgres) Get(ctx context.Context, id string) (*Record, error) {
+	r, err := scan(p.db.QueryRowContext(ctx, `SELECT `+columns+` FROM api_keys WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return r, err
+}
+
+func (p *Postgres) List(ctx context.Context, tenantID string) ([]*Key, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT `+columns+` FROM api_keys WHERE tenant_id = $1 ORDER BY created_at DESC`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*Key
+	for rows.Next() {
+		r, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &r.Key)
+	}
+	return keys, rows.Err()
+}
+
+func (p *Postgres) Touch(ctx context.Context, id string, t time.Time) error {
+	_, err := p.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, t)
+	return err
+}
+
+func (p *Postgres) Update(ctx context.Context, r *Record) error {
+	res, err := p.db.ExecContext(ctx, `UPDATE api_keys
+		SET name = $2, scopes = $3, salt = $4, hash = $5, rotated_at = $6, expires_at = $7, revoked_at = $8
+		WHERE id = $1`,
+		r.ID, r.Name, textArray(r.Scopes), r.Salt, r.Hash, r.RotatedAt, nullTime(r.ExpiresAt), nullTime(r.RevokedAt))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// textArray binds values to a TEXT[] column. pq sends a nil slice as NULL,
+// which the NOT NULL scopes column rejects.
+func textArray(values []string) interface{} {
+	if values == nil {
+		values = []string{}
+	}
+	return pq.Array(values)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row scanner) (*Record, error) {
+	var r Record
+	var expiresAt, revokedAt, lastUsedAt sql.NullTime
+	err := row.Scan(&r.ID, &r.TenantID, &r.Name, &r.CreatedBy, &r.Owner, pq.Array(&r.Scopes), &r.Salt, &r.Hash,
+		&r.CreatedAt, &r.RotatedAt, &expiresAt, &revokedAt, &lastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	r.ExpiresAt = expiresAt.Time
+	r.RevokedAt = revokedAt.Time
+	r.LastUsedAt = lastUsedAt.Time
+	return &r, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
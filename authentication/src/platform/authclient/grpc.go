@@ -0,0 +1,109 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+
+	"authentication/src/platform/authenticator"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authorizationKey is the metadata key tokens are sent under.
+const authorizationKey = "authorization"
+
+// UnaryServerInterceptor verifies the token in the authorization metadata
+// with v, checks reqs and makes the caller available through FromContext.
+func UnaryServerInterceptor(v Verifier, reqs ...Requirement) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, v, incomingToken(ctx), reqs)
+		if err != nil {
+			return nil, StatusError(err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func StreamServerInterceptor(v Verifier, reqs ...Requirement) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), v, incomingToken(ss.Context()), reqs)
+		if err != nil {
+			return StatusError(err)
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// UnaryClientInterceptor forwards the caller's token from ctx to the called
+// service, unless the call already carries one.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingToken(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor is the streaming counterpart of
+// UnaryClientInterceptor.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingToken(ctx), desc, cc, method, opts...)
+	}
+}
+
+// StatusError maps the package's errors to gRPC statuses.
+func StatusError(err error) error {
+	switch {
+	case errors.Is(err, ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, ErrMissingToken), errors.Is(err, ErrMalformedToken), errors.Is(err, ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, err.Error())
+	default:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+func incomingToken(ctx context.Context) string {
+	values := metadata.ValueFromIncomingContext(ctx, authorizationKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func outgoingToken(ctx context.Context) context.Context {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(authorizationKey)) > 0 {
+		return ctx
+	}
+	token, ok := TokenFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, authorizationKey, scheme(ctx)+" "+token)
+}
+
+// scheme is ApiKey for callers that authenticated with an API key or personal
+// access token, which are not bearer tokens, and Bearer for everyone else.
+func scheme(ctx context.Context) string {
+	if p, ok := FromContext(ctx); ok {
+		switch p.Kind {
+		case authenticator.KindAPIKey.String(), authenticator.KindPersonalToken.String():
+			return "ApiKey"
+		}
+	}
+	return "Bearer"
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,152 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// verifierFunc lets a function act as a Verifier.
+type verifierFunc func(ctx context.Context, token string) (*Principal, error)
+
+func (f verifierFunc) Verify(ctx context.Context, token string) (*Principal, error) {
+	return f(ctx, token)
+}
+
+// tokens verifies the tokens it knows, and fails like an unreachable auth
+// service for "down".
+func tokens(known map[string]*Principal) Verifier {
+	return verifierFunc(func(ctx context.Context, token string) (*Principal, error) {
+		if token == "down" {
+			return nil, errors.New("auth service unavailable")
+		}
+		if p, ok := known[token]; ok {
+			return p, nil
+		}
+		return nil, ErrInvalidToken
+	})
+}
+
+var testPrincipals = map[string]*Principal{
+	"user-token": {Subject: "user-1", Kind: "access_token", Scopes: []string{"reviews:write"}},
+	"dtk_key":    {Subject: "apikey|1", Kind: "api_key", Scopes: []string{"reviews:read"}},
+}
+
+func withAuthorization(value string) context.Context {
+	if value == "" {
+		return context.Background()
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", value))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor(tokens(testPrincipals), RequireScope("reviews:write"))
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          codes.Code
+	}{
+		{"bearer token", "Bearer user-token", codes.OK},
+		{"lower case scheme", "bearer user-token", codes.OK},
+		{"missing", "", codes.Unauthenticated},
+		{"no token", "Bearer ", codes.Unauthenticated},
+		{"basic", "Basic dXNlcjpwYXNz", codes.Unauthenticated},
+		{"invalid token", "Bearer forged", codes.Unauthenticated},
+		{"missing scope", "ApiKey dtk_key", codes.PermissionDenied},
+		{"verifier down", "Bearer down", codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var caller *Principal
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				caller, _ = FromContext(ctx)
+				if token, _ := TokenFromContext(ctx); token != "user-token" {
+					t.Errorf("token in context = %q", token)
+				}
+				return "ok", nil
+			}
+
+			_, err := interceptor(withAuthorization(tt.authorization), nil, &grpc.UnaryServerInfo{}, handler)
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("code = %s, want %s (%v)", got, tt.want, err)
+			}
+			if tt.want == codes.OK && (caller == nil || caller.Subject != "user-1") {
+				t.Errorf("caller = %+v", caller)
+			}
+			if tt.want != codes.OK && caller != nil {
+				t.Error("handler ran for a rejected call")
+			}
+		})
+	}
+}
+
+// stream is a grpc.ServerStream with a fixed context.
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stream) Context() context.Context { return s.ctx }
+
+func TestStreamServerInterceptor(t *testing.T) {
+	interceptor := StreamServerInterceptor(tokens(testPrincipals))
+
+	var caller *Principal
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		caller, _ = FromContext(ss.Context())
+		return nil
+	}
+	if err := interceptor(nil, &stream{ctx: withAuthorization("Bearer user-token")}, &grpc.StreamServerInfo{}, handler); err != nil {
+		t.Fatal(err)
+	}
+	if caller == nil || caller.Subject != "user-1" {
+		t.Errorf("caller = %+v", caller)
+	}
+
+	err := interceptor(nil, &stream{ctx: withAuthorization("")}, &grpc.StreamServerInfo{}, handler)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("code = %s, want Unauthenticated", status.Code(err))
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want []string
+	}{
+		{"access token", NewContext(context.Background(), testPrincipals["user-token"], "user-token"), []string{"Bearer user-token"}},
+		{"API key", NewContext(context.Background(), testPrincipals["dtk_key"], "dtk_key"), []string{"ApiKey dtk_key"}},
+		{"personal access token", NewContext(context.Background(), &Principal{Kind: "personal_access_token"}, "dtp_key"), []string{"ApiKey dtp_key"}},
+		{"no caller", context.Background(), nil},
+		{
+			"explicit credentials",
+			metadata.AppendToOutgoingContext(NewContext(context.Background(), testPrincipals["user-token"], "user-token"), "authorization", "Bearer service-token"),
+			[]string{"Bearer service-token"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []string
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				sent = md.Get("authorization")
+				return nil
+			}
+			if err := UnaryClientInterceptor()(tt.ctx, "/svc/Method", nil, nil, nil, invoker); err != nil {
+				t.Fatal(err)
+			}
+			if len(sent) != len(tt.want) || (len(sent) > 0 && sent[0] != tt.want[0]) {
+				t.Errorf("authorization = %q, want %q", sent, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package authclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware is the net/http counterpart of UnaryServerInterceptor. Failures
+// are answered as described in RFC 6750.
+func Middleware(v Verifier, reqs ...Requirement) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, err := authenticate(r.Context(), v, authorization(r), reqs)
+			if err != nil {
+				status, header := challenge(err)
+				w.Header().Set("WWW-Authenticate", header)
+				http.Error(w, err.Error(), status)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Gin is the gin counterpart of Middleware. Handlers read the caller with
+// FromContext(c.Request.Context()).
+func Gin(v Verifier, reqs ...Requirement) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, err := authenticate(c.Request.Context(), v, authorization(c.Request), reqs)
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// GinRequire checks reqs for routes below a Gin middleware.
+func GinRequire(reqs ...Requirement) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := Authorize(c.Request.Context(), reqs...); err != nil {
+			abort(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorization returns the Authorization header, or the X-Api-Key header
+// in the same form.
+func authorization(r *http.Request) string {
+	if key := r.Header.Get("X-Api-Key"); key != "" {
+		return "ApiKey " + key
+	}
+	return r.Header.Get("Authorization")
+}
+
+func abort(c *gin.Context, err error) {
+	status, header := challenge(err)
+	c.Header("WWW-Authenticate", header)
+	c.AbortWithStatusJSON(status, gin.H{"error": errorCode(err), "error_description": err.Error()})
+}
+
+// challenge returns the status and WWW-Authenticate header for err. Requests
+// without any credentials get no error code, as RFC 6750 section 3.1 asks.
+func challenge(err error) (int, string) {
+	status := http.StatusUnauthorized
+	switch {
+	case errors.Is(err, ErrMissingToken):
+		return status, `Bearer realm="api"`
+	case errors.Is(err, ErrMalformedToken):
+		status = http.StatusBadRequest
+	case errors.Is(err, ErrForbidden):
+		status = http.StatusForbidden
+	case !errors.Is(err, ErrInvalidToken):
+		status = http.StatusServiceUnavailable
+	}
+	return status, fmt.Sprintf(`Bearer realm="api", error=%q, error_description=%q`, errorCode(err), sanitize(err.Error()))
+}
+
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrMissingToken):
+		return ""
+	case errors.Is(err, ErrMalformedToken):
+		return "invalid_request"
+	case errors.Is(err, ErrForbidden):
+		return "insufficient_scope"
+	default:
+		return "invalid_token"
+	}
+}
+
+// sanitize keeps descriptions within the characters RFC 6750 allows.
+func sanitize(description string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return ' '
+		}
+		return r
+	}, description)
+}
@@ -0,0 +1,91 @@
+package authclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddleware(t *testing.T) {
+	handler := Middleware(tokens(testPrincipals), RequireScope("reviews:write"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := FromContext(r.Context())
+		w.Write([]byte(p.Subject))
+	}))
+
+	tests := []struct {
+		name      string
+		header    string
+		value     string
+		status    int
+		challenge string
+	}{
+		{"bearer token", "Authorization", "Bearer user-token", http.StatusOK, ""},
+		{"missing", "", "", http.StatusUnauthorized, `Bearer realm="api"`},
+		{"malformed", "Authorization", "user-token", http.StatusBadRequest, `error="invalid_request"`},
+		{"invalid token", "Authorization", "Bearer forged", http.StatusUnauthorized, `error="invalid_token"`},
+		{"API key header without scope", "X-Api-Key", "dtk_key", http.StatusForbidden, `error="insufficient_scope"`},
+		{"verifier down", "Authorization", "Bearer down", http.StatusServiceUnavailable, `error="invalid_token"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/reviews", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK {
+				if rec.Body.String() != "user-1" {
+					t.Errorf("body = %q", rec.Body.String())
+				}
+				return
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); !strings.Contains(got, tt.challenge) {
+				t.Errorf("WWW-Authenticate = %q, want it to contain %q", got, tt.challenge)
+			}
+		})
+	}
+}
+
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Gin(tokens(testPrincipals)))
+	router.GET("/reviews", func(c *gin.Context) {
+		p, _ := FromContext(c.Request.Context())
+		c.String(http.StatusOK, p.Subject)
+	})
+	router.POST("/reviews", GinRequire(RequireScope("reviews:write")), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	tests := []struct {
+		method string
+		key    string
+		status int
+	}{
+		{http.MethodGet, "dtk_key", http.StatusOK},
+		{http.MethodGet, "", http.StatusUnauthorized},
+		{http.MethodPost, "dtk_key", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/reviews", nil)
+		if tt.key != "" {
+			req.Header.Set("X-Api-Key", tt.key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s with key %q: status = %d, want %d", tt.method, tt.key, rec.Code, tt.status)
+		}
+	}
+}
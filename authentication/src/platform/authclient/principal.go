@@ -0,0 +1,126 @@
+package authclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	ErrMissingToken   = errors.New("no bearer token in the request")
+	ErrMalformedToken = errors.New("malformed authorization header")
+	ErrInvalidToken   = errors.New("token is invalid or expired")
+	ErrForbidden      = errors.New("caller is not allowed to do this")
+)
+
+// Principal is the verified caller, as returned by AuthService.VerifyToken.
+type Principal struct {
+	Subject  string
+	TenantID string
+	// Type is "user" or "service".
+	Type string
+	// Kind is "access_token", "id_token", "api_key" or
+	// "personal_access_token".
+	Kind          string
+	Scopes        []string
+	Roles         []string
+	Permissions   []string
+	Email         string
+	EmailVerified bool
+	IssuedAt      time.Time
+	ExpiresAt     time.Time
+	// Claims holds every claim of the token as decoded from JSON.
+	Claims map[string]interface{}
+}
+
+// HasScope reports whether the token was granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// HasRole reports whether the principal has role.
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// HasPermission reports whether the principal was granted permission.
+func (p *Principal) HasPermission(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
+
+// Requirement is a check a principal has to pass. Failures wrap ErrForbidden.
+type Requirement func(*Principal) error
+
+// RequireScope only lets principals with scope through.
+func RequireScope(scope string) Requirement {
+	return func(p *Principal) error {
+		if !p.HasScope(scope) {
+			return fmt.Errorf("%w: the %s scope is required", ErrForbidden, scope)
+		}
+		return nil
+	}
+}
+
+// RequireRole only lets principals with role through.
+func RequireRole(role string) Requirement {
+	return func(p *Principal) error {
+		if !p.HasRole(role) {
+			return fmt.Errorf("%w: the %s role is required", ErrForbidden, role)
+		}
+		return nil
+	}
+}
+
+// RequirePermission only lets principals with permission through.
+func RequirePermission(permission string) Requirement {
+	return func(p *Principal) error {
+		if !p.HasPermission(permission) {
+			return fmt.Errorf("%w: the %s permission is required", ErrForbidden, permission)
+		}
+		return nil
+	}
+}
+
+func check(p *Principal, reqs []Requirement) error {
+	for _, req := range reqs {
+		if err := req(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type principalKey struct{}
+
+type tokenKey struct{}
+
+// NewContext returns a copy of ctx carrying the caller and their raw token.
+// The client interceptors forward the token on outgoing calls.
+func NewContext(ctx context.Context, p *Principal, token string) context.Context {
+	ctx = context.WithValue(ctx, principalKey{}, p)
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// FromContext returns the caller stored by the interceptors or middleware.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// TokenFromContext returns the caller's raw token.
+func TokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(tokenKey{}).(string)
+	return token, ok && token != ""
+}
+
+// Authorize checks the caller in ctx against reqs. It is meant for handlers
+// that need different requirements per method.
+func Authorize(ctx context.Context, reqs ...Requirement) error {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return ErrMissingToken
+	}
+	return check(p, reqs)
+}
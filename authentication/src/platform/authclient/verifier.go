@@ -0,0 +1,215 @@
+package authclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pb "authentication/src/gen/proto"
+	"authentication/src/platform/authenticator"
+	"authentication/src/platform/identity"
+	"authentication/src/platform/revocation"
+
+	"google.golang.org/grpc"
+)
+
+// Verifier turns a raw bearer token into a *Principal.
+type Verifier interface {
+	Verify(ctx context.Context, token string) (*Principal, error)
+}
+
+// Remote verifies tokens by calling AuthService.VerifyToken.
+type Remote struct {
+	client   pb.AuthServiceClient
+	tenantID string
+}
+
+// NewRemote instantiates a *Remote using conn. tenantID may be empty for the
+// default tenant.
+func NewRemote(conn grpc.ClientConnInterface, tenantID string) *Remote {
+	return &Remote{client: pb.NewAuthServiceClient(conn), tenantID: tenantID}
+}
+
+func (r *Remote) Verify(ctx context.Context, token string) (*Principal, error) {
+	res, err := r.client.VerifyToken(ctx, &pb.VerifyTokenRequest{Token: token, TenantId: r.tenantID})
+	if err != nil {
+		return nil, fmt.Errorf("authclient: calling VerifyToken: %w", err)
+	}
+	if !res.IsValid {
+		return nil, ErrInvalidToken
+	}
+
+	p := &Principal{
+		Subject:       res.UserId,
+		TenantID:      res.TenantId,
+		Type:          remoteType(res.PrincipalType),
+		Kind:          remoteKind(res.TokenKind),
+		Scopes:        res.Scopes,
+		Roles:         res.Roles,
+		Permissions:   res.Permissions,
+		Email:         res.Email,
+		EmailVerified: res.EmailVerified,
+		Claims:        res.RawClaims.AsMap(),
+	}
+	if res.IssuedAt != nil {
+		p.IssuedAt = res.IssuedAt.AsTime()
+	}
+	if res.ExpiresAt != nil {
+		p.ExpiresAt = res.ExpiresAt.AsTime()
+	}
+	return p, nil
+}
+
+func remoteType(t pb.PrincipalType) string {
+	switch t {
+	case pb.PrincipalType_PRINCIPAL_TYPE_USER:
+		return identity.TypeUser
+	case pb.PrincipalType_PRINCIPAL_TYPE_SERVICE:
+		return identity.TypeService
+	case pb.PrincipalType_PRINCIPAL_TYPE_API_KEY:
+		return identity.TypeAPIKey
+	default:
+		return ""
+	}
+}
+
+func remoteKind(kind pb.TokenKind) string {
+	switch kind {
+	case pb.TokenKind_TOKEN_KIND_ID_TOKEN:
+		return authenticator.KindIDToken.String()
+	case pb.TokenKind_TOKEN_KIND_ACCESS_TOKEN:
+		return authenticator.KindAccessToken.String()
+	case pb.TokenKind_TOKEN_KIND_API_KEY:
+		return authenticator.KindAPIKey.String()
+	case pb.TokenKind_TOKEN_KIND_PERSONAL_ACCESS_TOKEN:
+		return authenticator.KindPersonalToken.String()
+	default:
+		return authenticator.KindUnknown.String()
+	}
+}
+
+// LocalConfig describes how a *Local verifies tokens.
+type LocalConfig struct {
+	// Auth is the identity provider tokens are issued by. Only discovery,
+	// the client ID and the audiences are needed, not the client secret.
+	Auth authenticator.Config
+	// Claims tells where custom claims such as roles are found.
+	Claims identity.Mapping
+	// TenantID is set on principals and, when tokens carry a tenant claim,
+	// has to match it.
+	TenantID string
+	// Revocations is the auth service's denylist, e.g. the Postgres store on
+	// its DATABASE_URL. Without it revoked tokens, and tokens issued before
+	// a user or tenant was logged out everywhere, verify until they expire.
+	Revocations revocation.Store
+}
+
+// Local verifies tokens in process against the provider's cached JWKS, so
+// no call to the auth service is made per request.
+type Local struct {
+	auth        *authenticator.Authenticator
+	claims      identity.Mapping
+	tenantID    string
+	revocations revocation.Store
+}
+
+// NewLocal instantiates a *Local. It runs provider discovery and starts
+// refreshing the key set in the background. Revocations are only seen when
+// cfg.Revocations is set; use a *Remote where they must take effect at once.
+func NewLocal(ctx context.Context, cfg LocalConfig) (*Local, error) {
+	auth, err := authenticator.NewWithConfig(ctx, cfg.Auth)
+	if err != nil {
+		return nil, err
+	}
+	return &Local{auth: auth, claims: cfg.Claims, tenantID: cfg.TenantID, revocations: cfg.Revocations}, nil
+}
+
+func (l *Local) Verify(ctx context.Context, token string) (*Principal, error) {
+	idToken, kind, err := l.auth.VerifyToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	ip, err := identity.FromToken(idToken, kind, l.claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if l.tenantID != "" {
+		if ip.TenantID != "" && ip.TenantID != l.tenantID {
+			return nil, fmt.Errorf("%w: token was not issued for this tenant", ErrInvalidToken)
+		}
+		ip.TenantID = l.tenantID
+	}
+	if err := l.checkRevoked(ctx, ip, token); err != nil {
+		return nil, err
+	}
+
+	return &Principal{
+		Subject:       ip.Subject,
+		TenantID:      ip.TenantID,
+		Type:          ip.Type,
+		Kind:          ip.Kind.String(),
+		Scopes:        ip.Scopes,
+		Roles:         ip.Roles,
+		Permissions:   ip.Permissions,
+		Email:         ip.Email,
+		EmailVerified: ip.EmailVerified,
+		IssuedAt:      ip.IssuedAt,
+		ExpiresAt:     ip.ExpiresAt,
+		Claims:        ip.Claims,
+	}, nil
+}
+
+// checkRevoked applies the denylist and not-before epochs like the auth
+// service does.
+func (l *Local) checkRevoked(ctx context.Context, ip *identity.Principal, token string) error {
+	if l.revocations == nil {
+		return nil
+	}
+	revoked, err := l.revocations.IsRevoked(ctx, revocation.Key(ip.TokenID, token))
+	if err != nil {
+		return fmt.Errorf("authclient: checking revocation: %w", err)
+	}
+	if revoked {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, revocation.ErrRevoked)
+	}
+
+	notBefore, err := l.revocations.NotBefore(ctx, ip.TenantID, ip.Subject)
+	if err != nil {
+		return fmt.Errorf("authclient: checking revocation: %w", err)
+	}
+	if revocation.Covers(notBefore, ip.IssuedAt) {
+		return fmt.Errorf("%w: %v", ErrInvalidToken, revocation.ErrRevoked)
+	}
+	return nil
+}
+
+// bearer extracts the token from an Authorization header value. API keys
+// may be sent with the ApiKey scheme.
+func bearer(header string) (string, error) {
+	if header == "" {
+		return "", ErrMissingToken
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || (!strings.EqualFold(scheme, "Bearer") && !strings.EqualFold(scheme, "ApiKey")) || token == "" {
+		return "", ErrMalformedToken
+	}
+	return token, nil
+}
+
+// authenticate is shared by the interceptors and middleware: it verifies
+// the Authorization header, checks reqs and stores the caller in ctx.
+func authenticate(ctx context.Context, v Verifier, header string, reqs []Requirement) (context.Context, error) {
+	token, err := bearer(header)
+	if err != nil {
+		return ctx, err
+	}
+	p, err := v.Verify(ctx, token)
+	if err != nil {
+		return ctx, err
+	}
+	if err := check(p, reqs); err != nil {
+		return ctx, err
+	}
+	return NewContext(ctx, p, token), nil
+}
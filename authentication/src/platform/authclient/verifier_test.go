@@ -0,0 +1,301 @@
+package authclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "authentication/src/gen/proto"
+	"authentication/src/platform/authenticator"
+	"authentication/src/platform/identity"
+	"authentication/src/platform/revocation"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// authService answers VerifyToken from a fixed set of tokens.
+type authService struct {
+	pb.UnimplementedAuthServiceServer
+	tokens map[string]*pb.VerifyTokenResponse
+	// tenants records the tenant of every request.
+	tenants []string
+}
+
+func (s *authService) VerifyToken(ctx context.Context, req *pb.VerifyTokenRequest) (*pb.VerifyTokenResponse, error) {
+	s.tenants = append(s.tenants, req.TenantId)
+	if res, ok := s.tokens[req.Token]; ok {
+		return res, nil
+	}
+	return &pb.VerifyTokenResponse{IsValid: false}, nil
+}
+
+// dial serves svc in memory and returns a connection to it.
+func dial(t *testing.T, svc pb.AuthServiceServer) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterAuthServiceServer(server, svc)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///auth",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestRemote(t *testing.T) {
+	issued := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	claims, err := structpb.NewStruct(map[string]interface{}{"sub": "user-1", "azp": "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := &authService{tokens: map[string]*pb.VerifyTokenResponse{
+		"user-token": {
+			IsValid:       true,
+			UserId:        "user-1",
+			TenantId:      "shop",
+			PrincipalType: pb.PrincipalType_PRINCIPAL_TYPE_USER,
+			TokenKind:     pb.TokenKind_TOKEN_KIND_ACCESS_TOKEN,
+			Scopes:        []string{"reviews:write"},
+			Roles:         []string{"customer"},
+			Email:         "ana@example.com",
+			EmailVerified: true,
+			IssuedAt:      timestamppb.New(issued),
+			ExpiresAt:     timestamppb.New(issued.Add(time.Hour)),
+			RawClaims:     claims,
+		},
+		"dtk_key": {
+			IsValid:       true,
+			UserId:        "apikey|1",
+			TenantId:      "shop",
+			PrincipalType: pb.PrincipalType_PRINCIPAL_TYPE_API_KEY,
+			TokenKind:     pb.TokenKind_TOKEN_KIND_API_KEY,
+		},
+	}}
+	v := NewRemote(dial(t, svc), "shop")
+
+	p, err := v.Verify(context.Background(), "user-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "user-1" || p.TenantID != "shop" || p.Type != identity.TypeUser || p.Kind != "access_token" {
+		t.Errorf("principal = %+v", p)
+	}
+	if !p.HasScope("reviews:write") || !p.HasRole("customer") || p.Email != "ana@example.com" || !p.EmailVerified {
+		t.Errorf("principal = %+v", p)
+	}
+	if !p.IssuedAt.Equal(issued) || !p.ExpiresAt.Equal(issued.Add(time.Hour)) || p.Claims["azp"] != "web" {
+		t.Errorf("principal = %+v", p)
+	}
+
+	p, err = v.Verify(context.Background(), "dtk_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != identity.TypeAPIKey || p.Kind != "api_key" || !p.IssuedAt.IsZero() {
+		t.Errorf("API key principal = %+v", p)
+	}
+
+	if _, err := v.Verify(context.Background(), "unknown"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("err = %v, want ErrInvalidToken", err)
+	}
+	for _, tenant := range svc.tenants {
+		if tenant != "shop" {
+			t.Errorf("VerifyToken called for tenant %q, want shop", tenant)
+		}
+	}
+}
+
+func TestRemoteUnavailable(t *testing.T) {
+	conn := dial(t, &authService{})
+	conn.Close()
+
+	_, err := NewRemote(conn, "").Verify(context.Background(), "token")
+	if err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Errorf("err = %v, want a failed call rather than an invalid token", err)
+	}
+}
+
+// issuer is an OpenID provider signing tokens with a single key.
+type issuer struct {
+	url string
+	key *ecdsa.PrivateKey
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &issuer{key: key}
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	iss.url = server.URL
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 iss.url,
+			"authorization_endpoint": iss.url + "/authorize",
+			"token_endpoint":         iss.url + "/token",
+			"jwks_uri":               iss.url + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "key", Algorithm: string(jose.ES256), Use: "sig"},
+		}})
+	})
+	return iss
+}
+
+// sign issues a token for the "api" audience with claims on top.
+func (iss *issuer) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	payload := map[string]interface{}{
+		"iss":   iss.url,
+		"sub":   "user-1",
+		"aud":   "api",
+		"jti":   "token-1",
+		"scope": "reviews:write",
+		"iat":   time.Now().Add(-time.Minute).Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		payload[name] = value
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := (&jose.SignerOptions{}).WithHeader(jose.HeaderKey("kid"), "key")
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: iss.key}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := signer.Sign(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func (iss *issuer) local(t *testing.T, revocations revocation.Store) *Local {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	l, err := NewLocal(ctx, LocalConfig{
+		Auth: authenticator.Config{
+			Provider:    authenticator.ProviderOIDC,
+			IssuerURL:   iss.url,
+			ClientID:    "web",
+			Audiences:   []string{"api"},
+			SigningAlgs: []string{string(jose.ES256)},
+		},
+		Claims:      identity.DefaultMapping(),
+		TenantID:    "shop",
+		Revocations: revocations,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestLocal(t *testing.T) {
+	iss := newIssuer(t)
+	l := iss.local(t, nil)
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		valid  bool
+	}{
+		{"access token", nil, true},
+		{"access token of the tenant", map[string]interface{}{"tenant_id": "shop"}, true},
+		{"access token of another tenant", map[string]interface{}{"tenant_id": "other"}, false},
+		{"other audience", map[string]interface{}{"aud": "billing"}, false},
+		{"expired", map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}, false},
+		{"other issuer", map[string]interface{}{"iss": "https://evil.example.com"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := l.Verify(context.Background(), iss.sign(t, tt.claims))
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("err = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Subject != "user-1" || p.TenantID != "shop" || p.Kind != "access_token" || !p.HasScope("reviews:write") {
+				t.Errorf("principal = %+v", p)
+			}
+		})
+	}
+
+	// A token of another provider does not verify against this one's keys
+	if _, err := l.Verify(context.Background(), newIssuer(t).sign(t, map[string]interface{}{"iss": iss.url})); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("foreign signature: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestLocalRevocation(t *testing.T) {
+	iss := newIssuer(t)
+	ctx := context.Background()
+
+	revoked := iss.sign(t, map[string]interface{}{"jti": "revoked"})
+	old := iss.sign(t, map[string]interface{}{"jti": "old", "sub": "user-2", "iat": time.Now().Add(-time.Hour).Unix()})
+	fresh := iss.sign(t, map[string]interface{}{"jti": "fresh", "sub": "user-2"})
+
+	revocations := revocation.NewMemory()
+	if err := revocations.Revoke(ctx, revocation.Key("revoked", revoked), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := revocations.RevokeBefore(ctx, "shop", "user-2", time.Now().Add(-30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the denylist revoked tokens verify until they expire
+	if _, err := iss.local(t, nil).Verify(ctx, revoked); err != nil {
+		t.Errorf("without revocations: %v", err)
+	}
+
+	l := iss.local(t, revocations)
+	for name, token := range map[string]string{"revoked token": revoked, "token before the cut-off": old} {
+		if _, err := l.Verify(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidToken", name, err)
+		}
+	}
+	if _, err := l.Verify(ctx, fresh); err != nil {
+		t.Errorf("token after the cut-off: %v", err)
+	}
+}
@@ -2,53 +2,35 @@ package authenticator
 
 import (
 	"context"
-	"errors"
-	"os"
 
-	"github.com/coreos/go-oidc/v3/oidc"
 	"golang.org/x/oauth2"
 )
 
 // Authenticator is used to authenticate our users.
 type Authenticator struct {
-	*oidc.Provider
-	oauth2.Config
+	IdentityProvider
 }
 
-// New instantiates the *Authenticator.
+// New instantiates the *Authenticator for the provider configured in the
+// environment.
 func New() (*Authenticator, error) {
-	provider, err := oidc.NewProvider(
-		context.Background(),
-		"https://"+os.Getenv("AUTH0_DOMAIN")+"/",
-	)
+	cfg, err := ConfigFromEnv()
 	if err != nil {
 		return nil, err
 	}
-
-	conf := oauth2.Config{
-		ClientID:     os.Getenv("AUTH0_CLIENT_ID"),
-		ClientSecret: os.Getenv("AUTH0_CLIENT_SECRET"),
-		RedirectURL:  os.Getenv("AUTH0_CALLBACK_URL"),
-		Endpoint:     provider.Endpoint(),
-		Scopes:       []string{oidc.ScopeOpenID, "profile"},
-	}
-
-	return &Authenticator{
-		Provider: provider,
-		Config:   conf,
-	}, nil
+	return NewWithConfig(context.Background(), cfg)
 }
 
-// VerifyIDToken verifies that an *oauth2.Token is a valid *oidc.IDToken.
-func (a *Authenticator) VerifyIDToken(ctx context.Context, token *oauth2.Token) (*oidc.IDToken, error) {
-	rawIDToken, ok := token.Extra("id_token").(string)
-	if !ok {
-		return nil, errors.New("no id_token field in oauth2 token")
-	}
-
-	oidcConfig := &oidc.Config{
-		ClientID: a.ClientID,
+// NewWithConfig instantiates the *Authenticator for cfg.
+func NewWithConfig(ctx context.Context, cfg Config) (*Authenticator, error) {
+	provider, err := NewProvider(ctx, cfg)
+	if err != nil {
+		return nil, err
 	}
+	return &Authenticator{IdentityProvider: provider}, nil
+}
 
-	return a.Verifier(oidcConfig).Verify(ctx, rawIDToken)
+// TokenSource returns a token source that refreshes token with the provider.
+func (a *Authenticator) TokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
+	return a.OAuth2().TokenSource(ctx, token)
 }
@@ -0,0 +1,101 @@
+package authenticator
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"authentication/src/platform/jwks"
+)
+
+const (
+	ProviderAuth0 = "auth0"
+	ProviderOIDC  = "oidc"
+)
+
+const defaultClockSkew = time.Minute
+
+// Config describes the identity provider and the client we use with it.
+type Config struct {
+	// Provider selects the implementation, ProviderAuth0 or ProviderOIDC.
+	Provider string
+	// IssuerURL is where discovery starts. For Auth0 it is derived from Domain.
+	IssuerURL string
+	// Domain is the Auth0 tenant domain.
+	Domain string
+
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are requested on top of openid and profile.
+	Scopes []string
+	// OfflineAccess asks the provider for refresh tokens.
+	OfflineAccess bool
+
+	// Issuer overrides the iss tokens have to carry. Defaults to the issuer
+	// from discovery.
+	Issuer string
+	// Audiences are the API audiences access tokens may be issued for.
+	Audiences []string
+	// SigningAlgs are the JWS algorithms tokens may be signed with.
+	SigningAlgs []string
+	// ClockSkew is how far behind the provider our clock may be.
+	ClockSkew time.Duration
+	// JWKS tunes the signing key cache.
+	JWKS jwks.Options
+}
+
+// ConfigFromEnv reads the provider configuration. AUTH_PROVIDER picks the
+// provider, "auth0" (the default) reads AUTH0_* and "oidc" reads OIDC_*.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Provider:    strings.ToLower(os.Getenv("AUTH_PROVIDER")),
+		Scopes:      splitList(os.Getenv("AUTH_SCOPES")),
+		Issuer:      os.Getenv("AUTH_ISSUER"),
+		Audiences:   splitList(os.Getenv("AUTH_AUDIENCE")),
+		SigningAlgs: splitList(os.Getenv("AUTH_SIGNING_ALGS")),
+		ClockSkew:   defaultClockSkew,
+	}
+	cfg.OfflineAccess, _ = strconv.ParseBool(os.Getenv("AUTH_OFFLINE_ACCESS"))
+
+	switch cfg.Provider {
+	case "", ProviderAuth0:
+		cfg.Provider = ProviderAuth0
+		cfg.Domain = os.Getenv("AUTH0_DOMAIN")
+		cfg.ClientID = os.Getenv("AUTH0_CLIENT_ID")
+		cfg.ClientSecret = os.Getenv("AUTH0_CLIENT_SECRET")
+		cfg.RedirectURL = os.Getenv("AUTH0_CALLBACK_URL")
+	case ProviderOIDC:
+		cfg.IssuerURL = os.Getenv("OIDC_ISSUER")
+		cfg.ClientID = os.Getenv("OIDC_CLIENT_ID")
+		cfg.ClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+		cfg.RedirectURL = os.Getenv("OIDC_CALLBACK_URL")
+	default:
+		return cfg, fmt.Errorf("unknown AUTH_PROVIDER %q", cfg.Provider)
+	}
+
+	var err error
+	if value := os.Getenv("AUTH_CLOCK_SKEW"); value != "" {
+		if cfg.ClockSkew, err = time.ParseDuration(value); err != nil {
+			return cfg, err
+		}
+	}
+
+	// Invalid values fall back to the cache defaults
+	cfg.JWKS.MaxStale, _ = time.ParseDuration(os.Getenv("JWKS_MAX_STALE"))
+	cfg.JWKS.UnknownKIDInterval, _ = time.ParseDuration(os.Getenv("JWKS_UNKNOWN_KID_INTERVAL"))
+
+	return cfg, nil
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,122 @@
+package authenticator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// backChannelLogoutEvent is the event a logout token has to carry.
+const backChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// logoutTokenMaxAge bounds the age of logout tokens that carry no exp.
+const logoutTokenMaxAge = 5 * time.Minute
+
+var (
+	ErrInvalidLogoutToken = errors.New("invalid logout token")
+	// ErrRevocationUnsupported means the provider has no revocation endpoint.
+	ErrRevocationUnsupported = errors.New("identity provider does not support token revocation")
+)
+
+// LogoutToken is a verified OpenID Connect Back-Channel Logout token. It
+// names the provider session, the user or both.
+type LogoutToken struct {
+	ID        string
+	Subject   string
+	SessionID string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// VerifyLogoutToken validates a back-channel logout token as described in
+// section 2.6 of the spec: signature, iss and aud like an ID token, the
+// logout event, sub or sid, jti and no nonce.
+func (p *oidcProvider) VerifyLogoutToken(ctx context.Context, rawToken string) (*LogoutToken, error) {
+	token, err := p.logoutVerifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLogoutToken, err)
+	}
+
+	var claims struct {
+		ID        string                     `json:"jti"`
+		SessionID string                     `json:"sid"`
+		Events    map[string]json.RawMessage `json:"events"`
+		Nonce     *string                    `json:"nonce"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLogoutToken, err)
+	}
+
+	switch {
+	case claims.Events == nil || claims.Events[backChannelLogoutEvent] == nil:
+		return nil, fmt.Errorf("%w: missing back-channel logout event", ErrInvalidLogoutToken)
+	case claims.Nonce != nil:
+		return nil, fmt.Errorf("%w: logout tokens must not carry a nonce", ErrInvalidLogoutToken)
+	case claims.ID == "":
+		return nil, fmt.Errorf("%w: missing jti", ErrInvalidLogoutToken)
+	case token.Subject == "" && claims.SessionID == "":
+		return nil, fmt.Errorf("%w: neither sub nor sid is set", ErrInvalidLogoutToken)
+	}
+
+	// The expiry check is skipped by the verifier as exp is optional here
+	now := time.Now()
+	if !token.Expiry.IsZero() && token.Expiry.Before(now.Add(-defaultClockSkew)) {
+		return nil, fmt.Errorf("%w: token has expired", ErrInvalidLogoutToken)
+	}
+	if token.Expiry.IsZero() && now.Sub(token.IssuedAt) > logoutTokenMaxAge {
+		return nil, fmt.Errorf("%w: token is too old", ErrInvalidLogoutToken)
+	}
+
+	expiresAt := token.Expiry
+	if expiresAt.IsZero() {
+		expiresAt = token.IssuedAt.Add(logoutTokenMaxAge)
+	}
+	return &LogoutToken{
+		ID:        claims.ID,
+		Subject:   token.Subject,
+		SessionID: claims.SessionID,
+		IssuedAt:  token.IssuedAt,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// RevokeRefreshToken revokes a refresh token at the provider's revocation
+// endpoint (RFC 7009).
+func (p *oidcProvider) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
+	if p.discovery.RevocationEndpoint == "" {
+		return ErrRevocationUnsupported
+	}
+
+	form := url.Values{
+		"token":           {refreshToken},
+		"token_type_hint": {"refresh_token"},
+		"client_id":       {p.config.ClientID},
+	}
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.RevocationEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return fmt.Errorf("revoking refresh token: %s: %s", resp.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"authentication/src/platform/jwks"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// oidcProvider is a standard OpenID Connect provider configured through
+// discovery, e.g. Keycloak, Dex or Zitadel.
+type oidcProvider struct {
+	provider  *oidc.Provider
+	config    oauth2.Config
+	discovery Discovery
+	audiences []string
+
+	idVerifier     *oidc.IDTokenVerifier
+	accessVerifier *oidc.IDTokenVerifier
+	logoutVerifier *oidc.IDTokenVerifier
+}
+
+func newOIDC(ctx context.Context, cfg Config) (*oidcProvider, error) {
+	if cfg.IssuerURL == "" {
+		return nil, errors.New("no issuer URL configured")
+	}
+
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var discovery Discovery
+	if err := provider.Claims(&discovery); err != nil {
+		return nil, err
+	}
+
+	// offline_access makes the provider hand out refresh tokens
+	scopes := append([]string{oidc.ScopeOpenID, "profile"}, cfg.Scopes...)
+	if cfg.OfflineAccess {
+		scopes = append(scopes, oidc.ScopeOfflineAccess)
+	}
+
+	conf := oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}
+
+	// From here on Issuer is the iss we expect, which may be overridden
+	if cfg.Issuer != "" {
+		discovery.Issuer = cfg.Issuer
+	}
+	issuer := discovery.Issuer
+
+	algs := cfg.SigningAlgs
+	if len(algs) == 0 {
+		algs = []string{oidc.RS256}
+	}
+
+	// Tolerate clocks running behind the provider's when checking expiry
+	skew := cfg.ClockSkew
+	now := func() time.Time { return time.Now().Add(-skew) }
+
+	// Keep a local copy of the provider's keys so verification survives outages
+	keySet := jwks.New(discovery.JWKSURL, cfg.JWKS)
+	keySet.Start(context.Background())
+
+	return &oidcProvider{
+		provider:  provider,
+		config:    conf,
+		discovery: discovery,
+		audiences: cfg.Audiences,
+		idVerifier: oidc.NewVerifier(issuer, keySet, &oidc.Config{
+			ClientID:             conf.ClientID,
+			SupportedSigningAlgs: algs,
+			Now:                  now,
+		}),
+		accessVerifier: oidc.NewVerifier(issuer, keySet, &oidc.Config{
+			SkipClientIDCheck:    true,
+			SupportedSigningAlgs: algs,
+			Now:                  now,
+		}),
+		// exp is optional in logout tokens, VerifyLogoutToken checks it
+		logoutVerifier: oidc.NewVerifier(issuer, keySet, &oidc.Config{
+			ClientID:             conf.ClientID,
+			SupportedSigningAlgs: algs,
+			SkipExpiryCheck:      true,
+		}),
+	}, nil
+}
+
+func (p *oidcProvider) Name() string {
+	return ProviderOIDC
+}
+
+func (p *oidcProvider) Discovery() Discovery {
+	return p.discovery
+}
+
+func (p *oidcProvider) OAuth2() *oauth2.Config {
+	return &p.config
+}
+
+func (p *oidcProvider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	return p.config.AuthCodeURL(state, opts...)
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return p.config.Exchange(ctx, code, opts...)
+}
+
+func (p *oidcProvider) VerifyIDToken(ctx context.Context, token *oauth2.Token) (*oidc.IDToken, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("no id_token field in oauth2 token")
+	}
+
+	return p.idVerifier.Verify(ctx, rawIDToken)
+}
+
+func (p *oidcProvider) UserInfo(ctx context.Context, token *oauth2.Token) (map[string]interface{}, error) {
+	info, err := p.provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+	if err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := info.Claims(&claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// LogoutURL uses the end_session_endpoint from discovery (OpenID Connect
+// RP-Initiated Logout). Providers without one only get a local logout.
+func (p *oidcProvider) LogoutURL(returnTo, idTokenHint, state string) (string, error) {
+	if p.discovery.EndSessionEndpoint == "" {
+		return withState(returnTo, state)
+	}
+
+	logoutURL, err := url.Parse(p.discovery.EndSessionEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	parameters := logoutURL.Query()
+	parameters.Set("client_id", p.config.ClientID)
+	if returnTo != "" {
+		parameters.Set("post_logout_redirect_uri", returnTo)
+	}
+	if idTokenHint != "" {
+		parameters.Set("id_token_hint", idTokenHint)
+	}
+	if state != "" {
+		parameters.Set("state", state)
+	}
+	logoutURL.RawQuery = parameters.Encode()
+
+	return logoutURL.String(), nil
+}
+
+// withState adds state to returnTo for providers that do not pass it back
+// themselves.
+func withState(returnTo, state string) (string, error) {
+	if returnTo == "" || state == "" {
+		return returnTo, nil
+	}
+	u, err := url.Parse(returnTo)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("state", state)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,56 @@
+package authenticator
+
+import (
+	"crypto/subtle"
+	"errors"
+	"regexp"
+
+	"golang.org/x/oauth2"
+)
+
+// ChallengeMethodS256 is the only PKCE challenge method we accept.
+const ChallengeMethodS256 = "S256"
+
+var (
+	ErrInvalidChallenge = errors.New("code_challenge must be a base64url encoded SHA-256 hash")
+	ErrChallengeMethod  = errors.New("code_challenge_method must be S256")
+	ErrVerifierMismatch = errors.New("code_verifier does not match code_challenge")
+)
+
+// A S256 challenge is an unpadded base64url SHA-256 digest, a verifier is
+// 43 to 128 unreserved characters (RFC 7636 section 4.1).
+var (
+	challengePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{43}$`)
+	verifierPattern  = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+)
+
+// ValidateChallenge checks a client supplied PKCE challenge.
+func ValidateChallenge(challenge, method string) error {
+	if method != ChallengeMethodS256 {
+		return ErrChallengeMethod
+	}
+	if !challengePattern.MatchString(challenge) {
+		return ErrInvalidChallenge
+	}
+	return nil
+}
+
+// VerifyChallenge checks that verifier hashes to challenge.
+func VerifyChallenge(verifier, challenge string) error {
+	if !verifierPattern.MatchString(verifier) {
+		return ErrVerifierMismatch
+	}
+	computed := oauth2.S256ChallengeFromVerifier(verifier)
+	if subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) != 1 {
+		return ErrVerifierMismatch
+	}
+	return nil
+}
+
+// ChallengeOptions add a client supplied S256 challenge to the auth URL.
+func ChallengeOptions(challenge string) []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("code_challenge_method", ChallengeMethodS256),
+		oauth2.SetAuthURLParam("code_challenge", challenge),
+	}
+}
@@ -0,0 +1,87 @@
+package authenticator
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestValidateChallenge(t *testing.T) {
+	valid := oauth2.S256ChallengeFromVerifier(oauth2.GenerateVerifier())
+
+	tests := []struct {
+		name      string
+		challenge string
+		method    string
+		want      error
+	}{
+		{"S256", valid, "S256", nil},
+		{"plain", valid, "plain", ErrChallengeMethod},
+		{"no method", valid, "", ErrChallengeMethod},
+		{"lower case method", valid, "s256", ErrChallengeMethod},
+		{"too short", valid[:42], "S256", ErrInvalidChallenge},
+		{"too long", valid + "A", "S256", ErrInvalidChallenge},
+		{"padded", valid[:42] + "=", "S256", ErrInvalidChallenge},
+		{"standard base64", "+" + valid[1:], "S256", ErrInvalidChallenge},
+		{"empty", "", "S256", ErrInvalidChallenge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateChallenge(tt.challenge, tt.method); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyChallenge(t *testing.T) {
+	verifier := oauth2.GenerateVerifier()
+	challenge := oauth2.S256ChallengeFromVerifier(verifier)
+
+	// RFC 7636 appendix B
+	const rfcVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	const rfcChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      error
+	}{
+		{"generated", verifier, challenge, nil},
+		{"RFC 7636 example", rfcVerifier, rfcChallenge, nil},
+		{"other verifier", oauth2.GenerateVerifier(), challenge, ErrVerifierMismatch},
+		{"challenge as verifier", challenge, challenge, ErrVerifierMismatch},
+		{"empty", "", challenge, ErrVerifierMismatch},
+		{"too short", rfcVerifier[:42], oauth2.S256ChallengeFromVerifier(rfcVerifier[:42]), ErrVerifierMismatch},
+		{"too long", strings.Repeat("a", 129), oauth2.S256ChallengeFromVerifier(strings.Repeat("a", 129)), ErrVerifierMismatch},
+		{"longest", strings.Repeat("a", 128), oauth2.S256ChallengeFromVerifier(strings.Repeat("a", 128)), nil},
+		{"reserved characters", strings.Repeat("a", 42) + "+", oauth2.S256ChallengeFromVerifier(strings.Repeat("a", 42) + "+"), ErrVerifierMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyChallenge(tt.verifier, tt.challenge); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestChallengeOptions(t *testing.T) {
+	challenge := oauth2.S256ChallengeFromVerifier(oauth2.GenerateVerifier())
+	conf := oauth2.Config{ClientID: "client", Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/authorize"}}
+
+	u, err := url.Parse(conf.AuthCodeURL("state", ChallengeOptions(challenge)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge") != challenge || q.Get("code_challenge_method") != ChallengeMethodS256 {
+		t.Errorf("auth URL query = %v", q)
+	}
+}
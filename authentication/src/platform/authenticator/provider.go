@@ -0,0 +1,57 @@
+package authenticator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Discovery is the provider metadata we rely on. Issuer is the iss tokens
+// have to carry, which Config.Issuer can override.
+type Discovery struct {
+	Issuer             string `json:"issuer"`
+	JWKSURL            string `json:"jwks_uri"`
+	EndSessionEndpoint string `json:"end_session_endpoint"`
+	RevocationEndpoint string `json:"revocation_endpoint"`
+}
+
+// IdentityProvider is an OpenID Connect provider users log in with.
+type IdentityProvider interface {
+	// Name identifies the implementation, e.g. ProviderAuth0.
+	Name() string
+	// Discovery returns the provider's metadata.
+	Discovery() Discovery
+	// OAuth2 returns the client configuration used with the provider.
+	OAuth2() *oauth2.Config
+	// AuthCodeURL returns the URL users are sent to for logging in.
+	AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string
+	// Exchange trades an authorization code for tokens.
+	Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
+	// VerifyIDToken verifies the id_token of a token response.
+	VerifyIDToken(ctx context.Context, token *oauth2.Token) (*oidc.IDToken, error)
+	// UserInfo returns the claims of the userinfo endpoint for token.
+	UserInfo(ctx context.Context, token *oauth2.Token) (map[string]interface{}, error)
+	// VerifyToken verifies a raw access token or ID token.
+	VerifyToken(ctx context.Context, rawToken string) (*oidc.IDToken, TokenKind, error)
+	// LogoutURL returns where to send users to end their provider session.
+	// The provider sends them back to returnTo with state.
+	LogoutURL(returnTo, idTokenHint, state string) (string, error)
+	// VerifyLogoutToken verifies a back-channel logout token.
+	VerifyLogoutToken(ctx context.Context, rawToken string) (*LogoutToken, error)
+	// RevokeRefreshToken revokes a refresh token at the provider.
+	RevokeRefreshToken(ctx context.Context, refreshToken string) error
+}
+
+// NewProvider instantiates the IdentityProvider selected by cfg.Provider.
+func NewProvider(ctx context.Context, cfg Config) (IdentityProvider, error) {
+	switch cfg.Provider {
+	case ProviderAuth0:
+		return newAuth0(ctx, cfg)
+	case ProviderOIDC:
+		return newOIDC(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("unknown identity provider %q", cfg.Provider)
+	}
+}
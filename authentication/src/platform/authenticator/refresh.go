@@ -0,0 +1,70 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrRefreshRejected means the refresh token expired, was revoked or was
+	// already rotated away. The user has to log in again.
+	ErrRefreshRejected = errors.New("refresh token was rejected")
+	// ErrClientRejected means the provider refused our client, e.g. because
+	// refresh tokens are not enabled for it.
+	ErrClientRejected = errors.New("client is not allowed to refresh tokens")
+	// ErrRateLimited means the provider asked us to slow down.
+	ErrRateLimited = errors.New("identity provider rate limit exceeded")
+	// ErrProviderUnavailable means the provider could not be reached or failed.
+	ErrProviderUnavailable = errors.New("identity provider is unavailable")
+)
+
+// Refresh exchanges a refresh token for a new token set. If the provider
+// rotates refresh tokens the returned token carries the new one, otherwise
+// the old one is kept.
+func (a *Authenticator) Refresh(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	if refreshToken == "" {
+		return nil, ErrRefreshRejected
+	}
+
+	token, err := a.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken}).Token()
+	if err != nil {
+		return nil, refreshError(err)
+	}
+	return token, nil
+}
+
+// IDToken returns the raw id_token of an *oauth2.Token, if the provider sent one.
+func IDToken(token *oauth2.Token) string {
+	idToken, _ := token.Extra("id_token").(string)
+	return idToken
+}
+
+// refreshError sorts a token endpoint failure into one of our sentinel errors.
+func refreshError(err error) error {
+	var re *oauth2.RetrieveError
+	if !errors.As(err, &re) {
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+
+	switch re.ErrorCode {
+	case "invalid_grant":
+		return fmt.Errorf("%w: %s", ErrRefreshRejected, re.ErrorDescription)
+	case "invalid_client", "unauthorized_client", "unsupported_grant_type":
+		return fmt.Errorf("%w: %s", ErrClientRejected, re.ErrorDescription)
+	}
+
+	switch {
+	case re.Response == nil:
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	case re.Response.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %v", ErrRateLimited, err)
+	case re.Response.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	default:
+		return fmt.Errorf("%w: %v", ErrRefreshRejected, err)
+	}
+}
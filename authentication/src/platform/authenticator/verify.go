@@ -0,0 +1,59 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// TokenKind tells which kind of token VerifyToken validated.
+type TokenKind int
+
+const (
+	KindUnknown TokenKind = iota
+	KindIDToken
+	KindAccessToken
+	// KindAPIKey is not a token of the provider but one of our API keys.
+	KindAPIKey
+	// KindPersonalToken is one of our personal access tokens.
+	KindPersonalToken
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case KindIDToken:
+		return "id_token"
+	case KindAccessToken:
+		return "access_token"
+	case KindAPIKey:
+		return "api_key"
+	case KindPersonalToken:
+		return "personal_access_token"
+	default:
+		return "unknown"
+	}
+}
+
+var ErrAudience = errors.New("token is not issued for this client or API")
+
+// VerifyToken verifies a raw JWT as either an access token for one of our API
+// audiences or an ID token for our client. Signature, issuer, algorithm and
+// expiry are checked for both.
+func (p *oidcProvider) VerifyToken(ctx context.Context, rawToken string) (*oidc.IDToken, TokenKind, error) {
+	token, err := p.accessVerifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, KindUnknown, err
+	}
+
+	for _, aud := range p.audiences {
+		if slices.Contains(token.Audience, aud) {
+			return token, KindAccessToken, nil
+		}
+	}
+	if slices.Contains(token.Audience, p.config.ClientID) {
+		return token, KindIDToken, nil
+	}
+	return nil, KindUnknown, ErrAudience
+}
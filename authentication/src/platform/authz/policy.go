@@ -0,0 +1,170 @@
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"authentication/src/platform/authenticator"
+	"authentication/src/platform/identity"
+)
+
+// Policy maps roles to the permissions they grant and, optionally, binds
+// subjects to roles per tenant for callers that do not carry a token.
+//
+// A permission is an action such as "reviews:moderate", optionally limited to
+// resources matching a glob with "@", e.g. "apps:publish@apps/42". A trailing
+// "*" in the action matches a whole namespace ("reviews:*"), and "*" alone
+// matches every action.
+type Policy struct {
+	Roles map[string][]string `json:"roles"`
+	// Subjects maps tenant -> subject -> roles.
+	Subjects map[string]map[string][]string `json:"subjects"`
+}
+
+// Subject is who a check is made for.
+type Subject struct {
+	ID          string
+	TenantID    string
+	Roles       []string
+	Permissions []string
+	// Delegated subjects, API keys and personal access tokens, only hold
+	// their own permissions and no roles bound in the policy.
+	Delegated bool
+}
+
+// SubjectOf is the subject for a verified principal.
+func SubjectOf(p *identity.Principal) Subject {
+	return Subject{
+		ID:          p.Subject,
+		TenantID:    p.TenantID,
+		Roles:       p.Roles,
+		Permissions: p.Permissions,
+		Delegated:   p.Kind == authenticator.KindAPIKey || p.Kind == authenticator.KindPersonalToken,
+	}
+}
+
+// Decision is the outcome of a check.
+type Decision struct {
+	Allowed bool
+	Reason  string
+}
+
+// DefaultPolicy is used when no AUTHZ_POLICY_FILE is configured.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Roles: map[string][]string{
+			"admin":     {"*"},
+			"moderator": {"reviews:read", "reviews:moderate"},
+			"support":   {"users:read", "users:block", "users:verify"},
+			"developer": {"apps:read", "apps:publish", "reviews:read"},
+			"customer":  {"apps:read", "reviews:read", "reviews:write"},
+		},
+	}
+}
+
+// Load reads the policy from AUTHZ_POLICY_FILE, or returns DefaultPolicy.
+func Load() (*Policy, error) {
+	file := os.Getenv("AUTHZ_POLICY_FILE")
+	if file == "" {
+		return DefaultPolicy(), nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	return &p, nil
+}
+
+// RolesOf returns the roles the policy binds to subject in tenant.
+func (p *Policy) RolesOf(tenantID, subject string) []string {
+	return p.Subjects[tenantID][subject]
+}
+
+// Check decides whether s may perform action on resource. Permissions held
+// directly by the subject are considered before the ones granted by roles.
+func (p *Policy) Check(s Subject, action, resource string) Decision {
+	if action == "" {
+		return Decision{Reason: "no action given"}
+	}
+
+	for _, permission := range s.Permissions {
+		if matches(permission, action, resource) {
+			return Decision{Allowed: true, Reason: "granted by permission " + permission}
+		}
+	}
+
+	for _, role := range p.roles(s) {
+		for _, permission := range p.Roles[role] {
+			if matches(permission, action, resource) {
+				return Decision{Allowed: true, Reason: fmt.Sprintf("granted by role %s (%s)", role, permission)}
+			}
+		}
+	}
+
+	reason := "no role or permission grants " + action
+	if resource != "" {
+		reason += " on " + resource
+	}
+	return Decision{Reason: reason}
+}
+
+// Permissions returns every permission s holds, directly or through roles.
+func (p *Policy) Permissions(s Subject) []string {
+	permissions := slices.Clone(s.Permissions)
+	for _, role := range p.roles(s) {
+		permissions = append(permissions, p.Roles[role]...)
+	}
+	slices.Sort(permissions)
+	return slices.Compact(permissions)
+}
+
+// roles returns the roles of s, including those bound in the policy.
+func (p *Policy) roles(s Subject) []string {
+	if s.Delegated {
+		return s.Roles
+	}
+	return append(slices.Clone(s.Roles), p.RolesOf(s.TenantID, s.ID)...)
+}
+
+// Missing returns the permissions in requested that s does not hold, e.g.
+// when s hands a subset of its permissions to a token.
+func (p *Policy) Missing(s Subject, requested []string) []string {
+	var missing []string
+	for _, permission := range requested {
+		action, resource, _ := strings.Cut(permission, "@")
+		if !p.Check(s, action, resource).Allowed {
+			missing = append(missing, permission)
+		}
+	}
+	return missing
+}
+
+// matches reports whether permission covers action on resource.
+func matches(permission, action, resource string) bool {
+	granted, pattern, scoped := strings.Cut(permission, "@")
+
+	switch {
+	case granted == "*":
+	case strings.HasSuffix(granted, "*"):
+		if !strings.HasPrefix(action, strings.TrimSuffix(granted, "*")) {
+			return false
+		}
+	case granted != action:
+		return false
+	}
+
+	if !scoped {
+		return true
+	}
+	ok, err := path.Match(pattern, resource)
+	return err == nil && ok
+}
@@ -0,0 +1,60 @@
+package bff
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"authentication/src/platform/redirect"
+)
+
+const (
+	// ModeBFF keeps tokens on the server. Browsers only get the session
+	// cookie and reach APIs through us.
+	ModeBFF = "bff"
+	// ModeLegacy hands the tokens to the browser after login, which keeps
+	// them in localStorage.
+	ModeLegacy = "legacy"
+)
+
+// Config is the backend-for-frontend setup of the web flow.
+type Config struct {
+	Mode string
+	// Upstreams are the backend services /api/proxy/<name>/ forwards to.
+	Upstreams map[string]*url.URL
+}
+
+// Legacy reports whether tokens are handed to the browser.
+func (c Config) Legacy() bool {
+	return c.Mode == ModeLegacy
+}
+
+// FromEnv reads AUTH_TOKEN_MODE, "bff" (the default) or "legacy", and
+// BFF_UPSTREAMS, a comma separated list of name=url pairs.
+func FromEnv() (Config, error) {
+	cfg := Config{
+		Mode:      strings.ToLower(os.Getenv("AUTH_TOKEN_MODE")),
+		Upstreams: make(map[string]*url.URL),
+	}
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = ModeBFF
+	case ModeBFF, ModeLegacy:
+	default:
+		return cfg, fmt.Errorf("unknown token mode %q", cfg.Mode)
+	}
+
+	for _, entry := range redirect.Split(os.Getenv("BFF_UPSTREAMS")) {
+		name, raw, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return cfg, fmt.Errorf("invalid BFF_UPSTREAMS entry %q", entry)
+		}
+		u, err := url.Parse(raw)
+		if err != nil || !u.IsAbs() || u.Host == "" {
+			return cfg, fmt.Errorf("invalid BFF_UPSTREAMS url %q", raw)
+		}
+		cfg.Upstreams[name] = u
+	}
+	return cfg, nil
+}
@@ -0,0 +1,74 @@
+package clientauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var ErrUnauthorized = errors.New("client authentication failed")
+
+// Clients are the confidential clients allowed to call our OAuth endpoints,
+// e.g. API gateways using token introspection. Only secret hashes are kept.
+type Clients struct {
+	secrets map[string][sha256.Size]byte
+}
+
+// New instantiates *Clients from client id -> secret pairs.
+func New(secrets map[string]string) *Clients {
+	c := &Clients{secrets: make(map[string][sha256.Size]byte, len(secrets))}
+	for id, secret := range secrets {
+		c.secrets[id] = sha256.Sum256([]byte(secret))
+	}
+	return c
+}
+
+// FromEnv reads INTROSPECTION_CLIENTS, a comma separated list of
+// client_id:client_secret pairs.
+func FromEnv() (*Clients, error) {
+	secrets := make(map[string]string)
+	for _, entry := range strings.Split(os.Getenv("INTROSPECTION_CLIENTS"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || secret == "" {
+			return nil, errors.New("INTROSPECTION_CLIENTS entries have to be client_id:client_secret")
+		}
+		secrets[id] = secret
+	}
+	return New(secrets), nil
+}
+
+// Authenticate checks the client credentials of r, sent either with HTTP
+// Basic authentication or as client_id and client_secret form parameters
+// (RFC 6749 section 2.3.1), and returns the client id.
+func (c *Clients) Authenticate(r *http.Request) (string, error) {
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		// Basic credentials are form encoded before being base64 encoded
+		var err error
+		if id, err = url.QueryUnescape(id); err != nil {
+			return "", ErrUnauthorized
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return "", ErrUnauthorized
+		}
+	} else {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if id == "" || secret == "" {
+		return "", ErrUnauthorized
+	}
+
+	want, known := c.secrets[id]
+	got := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(want[:], got[:]) != 1 || !known {
+		return "", ErrUnauthorized
+	}
+	return id, nil
+}
@@ -0,0 +1,213 @@
+package directory
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound        = errors.New("user not found")
+	ErrSubjectMismatch = errors.New("claims are about different subjects")
+)
+
+// User is what we know about a user of one tenant, taken from their ID token
+// and the provider's userinfo endpoint at their last login.
+type User struct {
+	ID            string
+	TenantID      string
+	Email         string
+	EmailVerified bool
+	Name          string
+	// DisplayName is the name shown to other users: the nickname, or the
+	// name, unless it is an email address.
+	DisplayName string
+	Picture     string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+	// Pseudonymous hides the user's name and picture from other users.
+	Pseudonymous bool
+}
+
+// PublicProfile is what other users may see of a user, e.g. next to their
+// reviews.
+type PublicProfile struct {
+	UserID       string
+	DisplayName  string
+	Picture      string
+	Pseudonymous bool
+}
+
+// Public returns the user's public profile. Pseudonymous users, and users
+// without a display name that is safe to show, get a name derived from their
+// ID, which stays the same across requests.
+func (u *User) Public() PublicProfile {
+	if u.Pseudonymous || u.DisplayName == "" || looksLikeEmail(u.DisplayName) {
+		sum := sha256.Sum256([]byte(u.TenantID + "|" + u.ID))
+		return PublicProfile{
+			UserID:       u.ID,
+			DisplayName:  "User " + hex.EncodeToString(sum[:3]),
+			Pseudonymous: true,
+		}
+	}
+	return PublicProfile{UserID: u.ID, DisplayName: u.DisplayName, Picture: u.Picture}
+}
+
+// looksLikeEmail reports whether a name could be an email address, which
+// providers such as Auth0 use as the default name.
+func looksLikeEmail(name string) bool {
+	return strings.Contains(name, "@")
+}
+
+// Membership is a tenant a user has logged in to. Memberships are matched
+// on the sub alone, callers have to make sure the tenants share a provider.
+type Membership struct {
+	TenantID    string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+// Store keeps the directory.
+type Store interface {
+	// Upsert saves u. An existing user keeps their CreatedAt and
+	// Pseudonymous, which are set on u.
+	Upsert(ctx context.Context, u *User) error
+	// Get returns the user with id in the tenant, or ErrNotFound.
+	Get(ctx context.Context, tenantID, id string) (*User, error)
+	// GetMany returns the users of the tenant among ids. Unknown IDs are
+	// skipped.
+	GetMany(ctx context.Context, tenantID string, ids []string) ([]*User, error)
+	// SetPseudonymous changes the user's display preference, or returns
+	// ErrNotFound.
+	SetPseudonymous(ctx context.Context, tenantID, id string, pseudonymous bool) error
+	// Delete forgets the user with id in the tenant.
+	Delete(ctx context.Context, tenantID, id string) error
+	// Memberships returns every tenant the user with id belongs to.
+	Memberships(ctx context.Context, id string) ([]Membership, error)
+}
+
+// FromEnv returns a Postgres backed Store when DATABASE_URL is set and an
+// in-memory one otherwise.
+func FromEnv(ctx context.Context) (Store, error) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		return NewMemory(), nil
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgres(ctx, db)
+}
+
+// Directory records users as they log in.
+type Directory struct {
+	store Store
+	now   func() time.Time
+}
+
+// New instantiates a *Directory backed by store.
+func New(store Store) *Directory {
+	return &Directory{store: store, now: time.Now}
+}
+
+// Record saves the user a login was for. claims are applied in order, so
+// userinfo passed after the ID token claims takes precedence. Every sub has
+// to be the same (OpenID Connect Core 5.3.2), otherwise nothing is saved.
+func (d *Directory) Record(ctx context.Context, tenantID string, claims ...map[string]interface{}) (*User, error) {
+	u := &User{TenantID: tenantID, LastLoginAt: d.now()}
+	var nickname string
+	for _, c := range claims {
+		if sub, ok := c["sub"].(string); ok && sub != "" && u.ID != "" && sub != u.ID {
+			return nil, ErrSubjectMismatch
+		}
+		set(&u.ID, c, "sub")
+		set(&u.Email, c, "email")
+		set(&nickname, c, "nickname")
+		set(&u.Name, c, "name")
+		set(&u.Picture, c, "picture")
+		if verified, ok := c["email_verified"].(bool); ok {
+			u.EmailVerified = verified
+		}
+	}
+	if u.ID == "" {
+		return nil, errors.New("claims have no sub")
+	}
+	if u.Name == "" {
+		u.Name = nickname
+	}
+	for _, name := range []string{nickname, u.Name} {
+		if name != "" && !looksLikeEmail(name) {
+			u.DisplayName = name
+			break
+		}
+	}
+	u.CreatedAt = u.LastLoginAt
+
+	if err := d.store.Upsert(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Get returns the user with id in the tenant and all their memberships.
+func (d *Directory) Get(ctx context.Context, tenantID, id string) (*User, []Membership, error) {
+	u, err := d.store.Get(ctx, tenantID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	memberships, err := d.store.Memberships(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, memberships, nil
+}
+
+// PublicProfiles returns the public profiles of the users of the tenant
+// among ids, in the order of ids. Users not in the directory are left out.
+func (d *Directory) PublicProfiles(ctx context.Context, tenantID string, ids []string) ([]PublicProfile, error) {
+	users, err := d.store.GetMany(ctx, tenantID, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	profiles := make([]PublicProfile, 0, len(users))
+	for _, id := range ids {
+		if u, ok := byID[id]; ok {
+			profiles = append(profiles, u.Public())
+			// Repeated IDs are only answered once
+			delete(byID, id)
+		}
+	}
+	return profiles, nil
+}
+
+// SetPseudonymous changes whether the user is shown under a pseudonym.
+func (d *Directory) SetPseudonymous(ctx context.Context, tenantID, id string, pseudonymous bool) (*User, error) {
+	if err := d.store.SetPseudonymous(ctx, tenantID, id, pseudonymous); err != nil {
+		return nil, err
+	}
+	return d.store.Get(ctx, tenantID, id)
+}
+
+// Delete forgets the user, e.g. after their account was deleted.
+func (d *Directory) Delete(ctx context.Context, tenantID, id string) error {
+	return d.store.Delete(ctx, tenantID, id)
+}
+
+// set copies a non-empty string claim to field.
+func set(field *string, claims map[string]interface{}, name string) {
+	if value, ok := claims[name].(string); ok && value != "" {
+		*field = value
+	}
+}
@@ -0,0 +1,102 @@
+package directory
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Memory is a Store for a single replica.
+type Memory struct {
+	mu    sync.Mutex
+	users map[key]User
+}
+
+type key struct {
+	tenantID string
+	id       string
+}
+
+// NewMemory instantiates an empty *Memory.
+func NewMemory() *Memory {
+	return &Memory{users: make(map[key]User)}
+}
+
+func (m *Memory) Upsert(ctx context.Context, u *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := key{tenantID: u.TenantID, id: u.ID}
+	if existing, ok := m.users[k]; ok {
+		u.CreatedAt = existing.CreatedAt
+		u.Pseudonymous = existing.Pseudonymous
+	}
+	m.users[k] = *u
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, tenantID, id string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[key{tenantID: tenantID, id: id}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+func (m *Memory) GetMany(ctx context.Context, tenantID string, ids []string) ([]*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var users []*User
+	for _, id := range ids {
+		if u, ok := m.users[key{tenantID: tenantID, id: id}]; ok {
+			users = append(users, &u)
+		}
+	}
+	return users, nil
+}
+
+func (m *Memory) SetPseudonymous(ctx context.Context, tenantID, id string, pseudonymous bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := key{tenantID: tenantID, id: id}
+	u, ok := m.users[k]
+	if !ok {
+		return ErrNotFound
+	}
+	u.Pseudonymous = pseudonymous
+	m.users[k] = u
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, tenantID, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.users, key{tenantID: tenantID, id: id})
+	return nil
+}
+
+func (m *Memory) Memberships(ctx context.Context, id string) ([]Membership, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var memberships []Membership
+	for k, u := range m.users {
+		if k.id == id {
+			memberships = append(memberships, Membership{
+				TenantID:    u.TenantID,
+				CreatedAt:   u.CreatedAt,
+				LastLoginAt: u.LastLoginAt,
+			})
+		}
+	}
+	slices.SortFunc(memberships, func(a, b Membership) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return memberships, nil
+}
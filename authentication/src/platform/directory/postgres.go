@@ -0,0 +1,131 @@
+package directory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Postgres is a Store shared by all replicas.
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres instantiates a *Postgres and creates its table if needed.
+func NewPostgres(ctx context.Context, db *sql.DB) (*Postgres, error) {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS users (
+		tenant_id      TEXT NOT NULL,
+		id             TEXT NOT NULL,
+		email          TEXT NOT NULL,
+		email_verified BOOLEAN NOT NULL,
+		name           TEXT NOT NULL,
+		display_name   TEXT NOT NULL DEFAULT '',
+		picture        TEXT NOT NULL,
+		created_at     TIMESTAMPTZ NOT NULL,
+		last_login_at  TIMESTAMPTZ NOT NULL,
+		pseudonymous   BOOLEAN NOT NULL DEFAULT false,
+		PRIMARY KEY (tenant_id, id)
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	return &Postgres{db: db}, nil
+}
+
+func (p *Postgres) Upsert(ctx context.Context, u *User) error {
+	return p.db.QueryRowContext(ctx, `INSERT INTO users
+		(tenant_id, id, email, email_verified, name, display_name, picture, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (tenant_id, id) DO UPDATE SET
+			email = EXCLUDED.email,
+			email_verified = EXCLUDED.email_verified,
+			name = EXCLUDED.name,
+			display_name = EXCLUDED.display_name,
+			picture = EXCLUDED.picture,
+			last_login_at = EXCLUDED.last_login_at
+		RETURNING created_at, pseudonymous`,
+		u.TenantID, u.ID, u.Email, u.EmailVerified, u.Name, u.DisplayName, u.Picture, u.CreatedAt, u.LastLoginAt,
+	).Scan(&u.CreatedAt, &u.Pseudonymous)
+}
+
+const columns = `tenant_id, id, email, email_verified, name, display_name, picture, created_at, last_login_at, pseudonymous`
+
+func (p *Postgres) Get(ctx context.Context, tenantID, id string) (*User, error) {
+	u, err := scan(p.db.QueryRowContext(ctx,
+		`SELECT `+columns+` FROM users WHERE tenant_id = $1 AND id = $2`, tenantID, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return u, err
+}
+
+func (p *Postgres) GetMany(ctx context.Context, tenantID string, ids []string) ([]*User, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT `+columns+` FROM users WHERE tenant_id = $1 AND id = ANY($2)`, tenantID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		u, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (p *Postgres) SetPseudonymous(ctx context.Context, tenantID, id string, pseudonymous bool) error {
+	res, err := p.db.ExecContext(ctx,
+		`UPDATE users SET pseudonymous = $3 WHERE tenant_id = $1 AND id = $2`, tenantID, id, pseudonymous)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (p *Postgres) Delete(ctx context.Context, tenantID, id string) error {
+	_, err := p.db.ExecContext(ctx, `DELETE FROM users WHERE tenant_id = $1 AND id = $2`, tenantID, id)
+	return err
+}
+
+func (p *Postgres) Memberships(ctx context.Context, id string) ([]Membership, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT tenant_id, created_at, last_login_at FROM users WHERE id = $1 ORDER BY created_at`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []Membership
+	for rows.Next() {
+		var m Membership
+		if err := rows.Scan(&m.TenantID, &m.CreatedAt, &m.LastLoginAt); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row scanner) (*User, error) {
+	var u User
+	err := row.Scan(&u.TenantID, &u.ID, &u.Email, &u.EmailVerified, &u.Name, &u.DisplayName, &u.Picture,
+		&u.CreatedAt, &u.LastLoginAt, &u.Pseudonymous)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
@@ -0,0 +1,170 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	pb "authentication/src/gen/proto"
+	"authentication/src/platform/management"
+	"authentication/src/platform/tenant"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Permissions the AdminService RPCs require.
+const (
+	blockUsers  = "users:block"
+	deleteUsers = "users:delete"
+	verifyUsers = "users:verify"
+)
+
+// AdminServer implements AdminService on top of the tenants' management
+// APIs. Callers are authenticated and authorized like the API key RPCs.
+type AdminServer struct {
+	pb.UnimplementedAdminServiceServer
+	auth *Server
+}
+
+func NewAdminServer(auth *Server) *AdminServer {
+	return &AdminServer{auth: auth}
+}
+
+func (a *AdminServer) SearchUsers(ctx context.Context, req *pb.SearchUsersRequest) (*pb.SearchUsersResponse, error) {
+	_, users, err := a.authorize(ctx, req.TenantId, readUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := users.Search(ctx, management.Query{
+		Text:    req.Query,
+		Page:    int(max(req.Page, 0)),
+		PerPage: int(req.PerPage),
+	})
+	if err != nil {
+		return nil, managementError(err)
+	}
+
+	res := &pb.SearchUsersResponse{
+		Users:   make([]*pb.AdminUser, len(page.Users)),
+		Total:   int32(page.Total),
+		Page:    int32(page.Page),
+		PerPage: int32(page.PerPage),
+	}
+	for i, u := range page.Users {
+		res.Users[i] = &pb.AdminUser{
+			Id:            u.ID,
+			Email:         u.Email,
+			EmailVerified: u.EmailVerified,
+			Name:          u.Name,
+			Picture:       u.Picture,
+			Blocked:       u.Blocked,
+			CreatedAt:     timestamp(u.CreatedAt),
+			LastLoginAt:   timestamp(u.LastLoginAt),
+			LoginsCount:   int32(u.LoginsCount),
+		}
+	}
+	return res, nil
+}
+
+func (a *AdminServer) BlockUser(ctx context.Context, req *pb.AdminUserRequest) (*pb.AdminActionResponse, error) {
+	t, users, err := a.authorizeUser(ctx, req, blockUsers)
+	if err != nil {
+		return nil, err
+	}
+	if err := users.Block(ctx, req.UserId); err != nil {
+		return nil, managementError(err)
+	}
+
+	// Blocking only stops new logins, the tokens they hold have to go too
+	a.signOut(ctx, t, req.UserId)
+	return &pb.AdminActionResponse{}, nil
+}
+
+func (a *AdminServer) UnblockUser(ctx context.Context, req *pb.AdminUserRequest) (*pb.AdminActionResponse, error) {
+	_, users, err := a.authorizeUser(ctx, req, blockUsers)
+	if err != nil {
+		return nil, err
+	}
+	if err := users.Unblock(ctx, req.UserId); err != nil {
+		return nil, managementError(err)
+	}
+	return &pb.AdminActionResponse{}, nil
+}
+
+func (a *AdminServer) DeleteUser(ctx context.Context, req *pb.AdminUserRequest) (*pb.AdminActionResponse, error) {
+	t, users, err := a.authorizeUser(ctx, req, deleteUsers)
+	if err != nil {
+		return nil, err
+	}
+	if err := users.Delete(ctx, req.UserId); err != nil {
+		return nil, managementError(err)
+	}
+
+	a.signOut(ctx, t, req.UserId)
+	if err := a.auth.users.Delete(ctx, t.ID, req.UserId); err != nil {
+		log.Printf("failed to remove deleted user %s from the directory: %v", req.UserId, err)
+	}
+	return &pb.AdminActionResponse{}, nil
+}
+
+func (a *AdminServer) ResendVerificationEmail(ctx context.Context, req *pb.AdminUserRequest) (*pb.AdminActionResponse, error) {
+	_, users, err := a.authorizeUser(ctx, req, verifyUsers)
+	if err != nil {
+		return nil, err
+	}
+	if err := users.ResendVerificationEmail(ctx, req.UserId); err != nil {
+		return nil, managementError(err)
+	}
+	return &pb.AdminActionResponse{}, nil
+}
+
+// authorize checks that the caller may perform action in the tenant and
+// returns the tenant with its management client.
+func (a *AdminServer) authorize(ctx context.Context, tenantID, action string) (*tenant.Tenant, management.Users, error) {
+	caller, err := a.auth.authorize(ctx, tenantID, action)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := a.auth.tenant(caller.TenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if t.Users == nil {
+		return nil, nil, status.Errorf(codes.FailedPrecondition, "tenant %s has no management API configured", t.ID)
+	}
+	return t, t.Users, nil
+}
+
+// authorizeUser is authorize for the RPCs acting on a single user.
+func (a *AdminServer) authorizeUser(ctx context.Context, req *pb.AdminUserRequest, action string) (*tenant.Tenant, management.Users, error) {
+	// An empty subject would revoke the tokens of the whole tenant
+	if req.UserId == "" {
+		return nil, nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	return a.authorize(ctx, req.TenantId, action)
+}
+
+// signOut revokes every token the user holds. The provider change already
+// happened, so failures are only logged.
+func (a *AdminServer) signOut(ctx context.Context, t *tenant.Tenant, userID string) {
+	if _, err := a.auth.tenants.RevokeAll(ctx, t.ID, userID); err != nil {
+		log.Printf("failed to revoke the tokens of %s: %v", userID, err)
+	}
+}
+
+func managementError(err error) error {
+	switch {
+	case errors.Is(err, management.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, management.ErrRateLimited):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, management.ErrUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Errorf(codes.Internal, "user administration failed: %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb "authentication/src/gen/proto"
+	"authentication/src/platform/apikey"
+	"authentication/src/platform/authenticator"
+	"authentication/src/platform/authz"
+	"authentication/src/platform/directory"
+	"authentication/src/platform/management"
+	"authentication/src/platform/revocation"
+	"authentication/src/platform/tenant"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testTenant = "shop"
+
+// adminFixture is an AdminServer for one tenant whose users are kept in a
+// management.Fake. Callers authenticate with API keys.
+type adminFixture struct {
+	admin       *AdminServer
+	users       *management.Fake
+	keys        *apikey.Keys
+	revocations revocation.Store
+}
+
+func newAdminFixture(t *testing.T, users ...management.User) *adminFixture {
+	t.Helper()
+
+	// Discovery is all the tenant's authenticator needs, API keys are
+	// verified without the provider
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"keys":[]}`))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	auth, err := authenticator.NewWithConfig(ctx, authenticator.Config{
+		Provider:  authenticator.ProviderOIDC,
+		IssuerURL: server.URL,
+		ClientID:  "client",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := management.NewFake(users...)
+	tenants, err := tenant.New(testTenant, &tenant.Tenant{ID: testTenant, Auth: auth, Users: fake})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revocations := revocation.NewMemory()
+	tenants.SetRevocations(revocations)
+	keys := apikey.New(apikey.NewMemory())
+	tenants.SetAPIKeys(keys)
+
+	srv := NewServer(tenants, nil, nil, authz.DefaultPolicy(), nil, keys, directory.New(directory.NewMemory()))
+	return &adminFixture{admin: NewAdminServer(srv), users: fake, keys: keys, revocations: revocations}
+}
+
+// as returns a context authenticated with a new API key holding scopes.
+func (f *adminFixture) as(t *testing.T, scopes ...string) context.Context {
+	t.Helper()
+	_, raw, err := f.keys.Create(context.Background(), apikey.Key{TenantID: testTenant, Name: "test", Scopes: scopes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "ApiKey "+raw))
+}
+
+// signedOut reports whether the tokens of userID have been revoked.
+func (f *adminFixture) signedOut(t *testing.T, userID string) bool {
+	t.Helper()
+	notBefore, err := f.revocations.NotBefore(context.Background(), testTenant, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return !notBefore.IsZero()
+}
+
+func (f *adminFixture) user(t *testing.T, id string) (management.User, bool) {
+	t.Helper()
+	page, err := f.users.Search(context.Background(), management.Query{Text: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Users) == 0 {
+		return management.User{}, false
+	}
+	return page.Users[0], true
+}
+
+func TestAdminRequiresPermission(t *testing.T) {
+	f := newAdminFixture(t, management.User{ID: "user-1", Email: "ana@example.com"})
+	req := &pb.AdminUserRequest{TenantId: testTenant, UserId: "user-1"}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		call   func(context.Context) error
+	}{
+		{"block without users:block", []string{"users:read", "users:delete"}, func(ctx context.Context) error {
+			_, err := f.admin.BlockUser(ctx, req)
+			return err
+		}},
+		{"unblock without users:block", []string{"users:read"}, func(ctx context.Context) error {
+			_, err := f.admin.UnblockUser(ctx, req)
+			return err
+		}},
+		{"delete without users:delete", []string{"users:block"}, func(ctx context.Context) error {
+			_, err := f.admin.DeleteUser(ctx, req)
+			return err
+		}},
+		{"resend without users:verify", []string{"users:block"}, func(ctx context.Context) error {
+			_, err := f.admin.ResendVerificationEmail(ctx, req)
+			return err
+		}},
+		{"search without users:read", []string{"users:block"}, func(ctx context.Context) error {
+			_, err := f.admin.SearchUsers(ctx, &pb.SearchUsersRequest{TenantId: testTenant})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(f.as(t, tt.scopes...)); status.Code(err) != codes.PermissionDenied {
+				t.Errorf("err = %v, want PermissionDenied", err)
+			}
+		})
+	}
+
+	if _, err := f.admin.BlockUser(context.Background(), req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("without credentials err = %v, want Unauthenticated", err)
+	}
+
+	u, _ := f.user(t, "user-1")
+	if u.Blocked {
+		t.Error("a denied call blocked the user")
+	}
+	if f.signedOut(t, "user-1") {
+		t.Error("a denied call revoked the user's tokens")
+	}
+	if len(f.users.Verifications) != 0 {
+		t.Errorf("a denied call sent verification emails to %v", f.users.Verifications)
+	}
+}
+
+func TestBlockUserRevokesTokens(t *testing.T) {
+	f := newAdminFixture(t, management.User{ID: "user-1"}, management.User{ID: "user-2"})
+
+	_, err := f.admin.BlockUser(f.as(t, "users:block"), &pb.AdminUserRequest{TenantId: testTenant, UserId: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u, _ := f.user(t, "user-1"); !u.Blocked {
+		t.Error("user was not blocked at the provider")
+	}
+	if !f.signedOut(t, "user-1") {
+		t.Error("tokens of the blocked user were not revoked")
+	}
+	if f.signedOut(t, "user-2") {
+		t.Error("tokens of another user were revoked")
+	}
+}
+
+func TestUnblockUserKeepsRevocations(t *testing.T) {
+	f := newAdminFixture(t, management.User{ID: "user-1", Blocked: true})
+
+	_, err := f.admin.UnblockUser(f.as(t, "users:block"), &pb.AdminUserRequest{TenantId: testTenant, UserId: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := f.user(t, "user-1"); u.Blocked {
+		t.Error("user is still blocked")
+	}
+	if f.signedOut(t, "user-1") {
+		t.Error("unblocking revoked the user's tokens")
+	}
+}
+
+func TestDeleteUserRevokesTokens(t *testing.T) {
+	f := newAdminFixture(t, management.User{ID: "user-1"})
+
+	_, err := f.admin.DeleteUser(f.as(t, "users:delete"), &pb.AdminUserRequest{TenantId: testTenant, UserId: "user-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := f.user(t, "user-1"); ok {
+		t.Error("user was not deleted at the provider")
+	}
+	if !f.signedOut(t, "user-1") {
+		t.Error("tokens of the deleted user were not revoked")
+	}
+}
+
+func TestAdminUnknownUser(t *testing.T) {
+	f := newAdminFixture(t)
+
+	_, err := f.admin.BlockUser(f.as(t, "users:block"), &pb.AdminUserRequest{TenantId: testTenant, UserId: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("err = %v, want NotFound", err)
+	}
+	if f.signedOut(t, "missing") {
+		t.Error("tokens were revoked although blocking failed")
+	}
+}
+
+func TestAdminRequiresUserID(t *testing.T) {
+	f := newAdminFixture(t, management.User{ID: "user-1"})
+
+	// An empty user would revoke the tokens of the whole tenant
+	_, err := f.admin.BlockUser(f.as(t, "users:block"), &pb.AdminUserRequest{TenantId: testTenant})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("err = %v, want InvalidArgument", err)
+	}
+	if f.signedOut(t, "") {
+		t.Error("the tenant's tokens were revoked")
+	}
+}
@@ -0,0 +1,126 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	pb "authentication/src/gen/proto"
+	"authentication/src/platform/apikey"
+	"authentication/src/platform/authz"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// manageAPIKeys is the permission the API key management RPCs require.
+const manageAPIKeys = "apikeys:manage"
+
+func (s *Server) CreateApiKey(ctx context.Context, req *pb.CreateApiKeyRequest) (*pb.ApiKeySecret, error) {
+	caller, err := s.authorize(ctx, req.TenantId, manageAPIKeys)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.AsTime().After(time.Now()) {
+		return nil, status.Error(codes.InvalidArgument, "expires_at is in the past")
+	}
+	// Keys must not grant more than their creator holds
+	if missing := s.policy.Missing(authz.SubjectOf(caller), req.Scopes); len(missing) > 0 {
+		return nil, status.Errorf(codes.PermissionDenied, "caller does not hold %s", strings.Join(missing, ", "))
+	}
+
+	key := apikey.Key{
+		TenantID:  caller.TenantID,
+		Name:      req.Name,
+		CreatedBy: caller.Subject,
+		Scopes:    req.Scopes,
+	}
+	if req.ExpiresAt != nil {
+		key.ExpiresAt = req.ExpiresAt.AsTime()
+	}
+
+	created, secret, err := s.apiKeys.Create(ctx, key)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create API key: %v", err)
+	}
+	return &pb.ApiKeySecret{Key: apiKey(created), Secret: secret}, nil
+}
+
+func (s *Server) ListApiKeys(ctx context.Context, req *pb.ListApiKeysRequest) (*pb.ListApiKeysResponse, error) {
+	caller, err := s.authorize(ctx, req.TenantId, manageAPIKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.apiKeys.List(ctx, caller.TenantID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list API keys: %v", err)
+	}
+
+	res := &pb.ListApiKeysResponse{Keys: make([]*pb.ApiKey, len(keys))}
+	for i, key := range keys {
+		res.Keys[i] = apiKey(key)
+	}
+	return res, nil
+}
+
+func (s *Server) RevokeApiKey(ctx context.Context, req *pb.RevokeApiKeyRequest) (*pb.ApiKey, error) {
+	caller, err := s.authorize(ctx, req.TenantId, manageAPIKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.apiKeys.Revoke(ctx, caller.TenantID, req.Id)
+	if err != nil {
+		return nil, apiKeyError(err)
+	}
+	return apiKey(key), nil
+}
+
+func (s *Server) RotateApiKey(ctx context.Context, req *pb.RotateApiKeyRequest) (*pb.ApiKeySecret, error) {
+	caller, err := s.authorize(ctx, req.TenantId, manageAPIKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	key, secret, err := s.apiKeys.Rotate(ctx, caller.TenantID, req.Id)
+	if err != nil {
+		return nil, apiKeyError(err)
+	}
+	return &pb.ApiKeySecret{Key: apiKey(key), Secret: secret}, nil
+}
+
+func (s *Server) VerifyApiKey(ctx context.Context, req *pb.VerifyApiKeyRequest) (*pb.VerifyTokenResponse, error) {
+	if !apikey.IsKey(req.Key) {
+		return &pb.VerifyTokenResponse{IsValid: false}, nil
+	}
+	return s.VerifyToken(ctx, &pb.VerifyTokenRequest{Token: req.Key, TenantId: req.TenantId})
+}
+
+func apiKey(key *apikey.Key) *pb.ApiKey {
+	return &pb.ApiKey{
+		Id:         key.ID,
+		TenantId:   key.TenantID,
+		Name:       key.Name,
+		Display:    key.Display(),
+		Scopes:     key.Scopes,
+		CreatedBy:  key.CreatedBy,
+		CreatedAt:  timestamp(key.CreatedAt),
+		RotatedAt:  timestamp(key.RotatedAt),
+		ExpiresAt:  timestamp(key.ExpiresAt),
+		RevokedAt:  timestamp(key.RevokedAt),
+		LastUsedAt: timestamp(key.LastUsedAt),
+		Owner:      key.Owner,
+	}
+}
+
+func apiKeyError(err error) error {
+	if errors.Is(err, apikey.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Errorf(codes.Internal, "API key operation failed: %v", err)
+}
@@ -0,0 +1,69 @@
+package grpc
+
+import (
+	pb "authentication/src/gen/proto"
+	"authentication/src/platform/authz"
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBatchChecks caps BatchCheckPermission requests.
+const maxBatchChecks = 100
+
+func (s *Server) CheckPermission(ctx context.Context, req *pb.CheckPermissionRequest) (*pb.CheckPermissionResponse, error) {
+	subject, denied, err := s.authzSubject(ctx, req.GetToken(), req.GetSubject(), req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+	if denied != nil {
+		return denied, nil
+	}
+
+	decision := s.policy.Check(subject, req.Action, req.Resource)
+	return &pb.CheckPermissionResponse{Allowed: decision.Allowed, Reason: decision.Reason}, nil
+}
+
+func (s *Server) BatchCheckPermission(ctx context.Context, req *pb.BatchCheckPermissionRequest) (*pb.BatchCheckPermissionResponse, error) {
+	if len(req.Checks) > maxBatchChecks {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d checks are allowed per request", maxBatchChecks)
+	}
+
+	subject, denied, err := s.authzSubject(ctx, req.GetToken(), req.GetSubject(), req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*pb.CheckPermissionResponse, len(req.Checks))
+	for i, check := range req.Checks {
+		if denied != nil {
+			results[i] = denied
+			continue
+		}
+		decision := s.policy.Check(subject, check.Action, check.Resource)
+		results[i] = &pb.CheckPermissionResponse{Allowed: decision.Allowed, Reason: decision.Reason}
+	}
+	return &pb.BatchCheckPermissionResponse{Results: results}, nil
+}
+
+// authzSubject resolves who a permission check is for. An invalid token is
+// not an error but denies every check, with the reason returned in denied.
+func (s *Server) authzSubject(ctx context.Context, token, subject, tenantID string) (authz.Subject, *pb.CheckPermissionResponse, error) {
+	switch {
+	case token != "":
+		principal, err := s.tenants.Authenticate(ctx, token, tenantID)
+		if err != nil {
+			return authz.Subject{}, &pb.CheckPermissionResponse{Reason: "invalid token: " + err.Error()}, nil
+		}
+		return authz.SubjectOf(principal), nil, nil
+	case subject != "":
+		t, err := s.tenant(tenantID)
+		if err != nil {
+			return authz.Subject{}, nil, err
+		}
+		return authz.Subject{ID: subject, TenantID: t.ID}, nil, nil
+	default:
+		return authz.Subject{}, nil, status.Error(codes.InvalidArgument, "token or subject is required")
+	}
+}
@@ -0,0 +1,47 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"authentication/src/platform/authz"
+	"authentication/src/platform/identity"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// caller authenticates the caller from the authorization metadata, as
+// "Bearer <token>" or "ApiKey <key>".
+func (s *Server) caller(ctx context.Context, tenantID string) (*identity.Principal, error) {
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata is required")
+	}
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || token == "" || (!strings.EqualFold(scheme, "Bearer") && !strings.EqualFold(scheme, "ApiKey")) {
+		return nil, status.Error(codes.Unauthenticated, "malformed authorization metadata")
+	}
+
+	principal, err := s.tenants.Authenticate(ctx, token, tenantID)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return principal, nil
+}
+
+// authorize authenticates the caller and checks that the policy allows them
+// action in the tenant.
+func (s *Server) authorize(ctx context.Context, tenantID, action string) (*identity.Principal, error) {
+	principal, err := s.caller(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := s.policy.Check(authz.SubjectOf(principal), action, "")
+	if !decision.Allowed {
+		return nil, status.Error(codes.PermissionDenied, decision.Reason)
+	}
+	return principal, nil
+}
@@ -0,0 +1,78 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	pb "authentication/src/gen/proto"
+	"authentication/src/platform/apikey"
+	"authentication/src/platform/authz"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) CreatePersonalAccessToken(ctx context.Context, req *pb.CreatePersonalAccessTokenRequest) (*pb.ApiKeySecret, error) {
+	caller, err := s.caller(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+	if !apikey.CanOwn(caller) {
+		return nil, status.Error(codes.PermissionDenied, "only signed in users can create personal access tokens")
+	}
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if req.ExpiresAt == nil {
+		return nil, status.Error(codes.InvalidArgument, "expires_at is required")
+	}
+	if missing := s.policy.Missing(authz.SubjectOf(caller), req.Permissions); len(missing) > 0 {
+		return nil, status.Errorf(codes.PermissionDenied, "caller does not hold %s", strings.Join(missing, ", "))
+	}
+
+	created, secret, err := s.apiKeys.CreatePersonal(ctx, apikey.Key{
+		TenantID:  caller.TenantID,
+		Name:      req.Name,
+		CreatedBy: caller.Subject,
+		Owner:     caller.Subject,
+		Scopes:    req.Permissions,
+		ExpiresAt: req.ExpiresAt.AsTime(),
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.ApiKeySecret{Key: apiKey(created), Secret: secret}, nil
+}
+
+func (s *Server) ListPersonalAccessTokens(ctx context.Context, req *pb.ListPersonalAccessTokensRequest) (*pb.ListApiKeysResponse, error) {
+	caller, err := s.caller(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.apiKeys.ListPersonal(ctx, caller.TenantID, caller.Subject)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list personal access tokens: %v", err)
+	}
+
+	res := &pb.ListApiKeysResponse{Keys: make([]*pb.ApiKey, len(keys))}
+	for i, key := range keys {
+		res.Keys[i] = apiKey(key)
+	}
+	return res, nil
+}
+
+// RevokePersonalAccessToken may be called with the token itself, so a
+// leaked token can be disabled with nothing but the token.
+func (s *Server) RevokePersonalAccessToken(ctx context.Context, req *pb.RevokePersonalAccessTokenRequest) (*pb.ApiKey, error) {
+	caller, err := s.caller(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.apiKeys.RevokePersonal(ctx, caller.TenantID, caller.Subject, req.Id)
+	if err != nil {
+		return nil, apiKeyError(err)
+	}
+	return apiKey(key), nil
+}
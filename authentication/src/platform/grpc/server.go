@@ -2,55 +2,110 @@ package grpc
 
 import (
 	pb "authentication/src/gen/proto"
+	"authentication/src/platform/apikey"
 	"authentication/src/platform/authenticator"
+	"authentication/src/platform/authz"
+	"authentication/src/platform/directory"
+	"authentication/src/platform/identity"
+	"authentication/src/platform/redirect"
+	"authentication/src/platform/service"
+	"authentication/src/platform/state"
+	"authentication/src/platform/tenant"
 	"context"
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
-	"fmt"
-	"net/url"
-	"os"
+	"errors"
+	"log"
+	"net/http"
+	"time"
 
-	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Server struct {
 	pb.UnimplementedAuthServiceServer
-	auth *authenticator.Authenticator
+	tenants   *tenant.Registry
+	states    *state.Store
+	redirects *redirect.AllowList
+	policy    *authz.Policy
+	services  *service.Registry
+	apiKeys   *apikey.Keys
+	users     *directory.Directory
 }
 
-func NewServer(auth *authenticator.Authenticator) *Server {
-	return &Server{auth: auth}
+func NewServer(tenants *tenant.Registry, states *state.Store, redirects *redirect.AllowList, policy *authz.Policy, services *service.Registry, apiKeys *apikey.Keys, users *directory.Directory) *Server {
+	return &Server{tenants: tenants, states: states, redirects: redirects, policy: policy, services: services, apiKeys: apiKeys, users: users}
 }
 
-func generateRandomState() (string, error) {
-	b := make([]byte, 32)
-	_, err := rand.Read(b)
+func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	t, err := s.tenant(req.TenantId)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return base64.StdEncoding.EncodeToString(b), nil
-}
 
-func (s *Server) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
-	state, err := generateRandomState()
+	st, err := state.Generate()
 	if err != nil {
 		return nil, err
 	}
 
-	authURL := s.auth.AuthCodeURL(state)
-	return &pb.LoginResponse{AuthUrl: authURL}, nil
+	flow := state.Flow{TenantID: t.ID}
+	if req.RedirectUrl != "" {
+		flow.RedirectURL, err = s.redirects.Check(t.ID, req.RedirectUrl)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	// Use the client's PKCE challenge if it sent one, otherwise keep our own verifier
+	var opts []oauth2.AuthCodeOption
+	if req.CodeChallenge != "" {
+		if err := authenticator.ValidateChallenge(req.CodeChallenge, req.CodeChallengeMethod); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		flow.CodeChallenge = req.CodeChallenge
+		opts = authenticator.ChallengeOptions(req.CodeChallenge)
+	} else {
+		flow.CodeVerifier = oauth2.GenerateVerifier()
+		opts = []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(flow.CodeVerifier)}
+	}
+
+	// The state is kept server side, keyed to the auth_url handed out here
+	flow.AuthURL = t.Auth.AuthCodeURL(st, opts...)
+	s.states.Save(st, flow)
+
+	return &pb.LoginResponse{AuthUrl: flow.AuthURL}, nil
 }
 
 func (s *Server) Verify(ctx context.Context, req *pb.VerifyRequest) (*pb.VerifyResponse, error) {
-	token, err := s.auth.Exchange(ctx, req.Code)
+	flow, err := s.states.Consume(req.State)
+	if err != nil {
+		return nil, stateError(err)
+	}
+	if req.TenantId != "" && req.TenantId != flow.TenantID {
+		return nil, stateError(state.ErrMismatch)
+	}
+	t, err := s.tenant(flow.TenantID)
 	if err != nil {
 		return nil, err
 	}
 
-	idToken, err := s.auth.VerifyIDToken(ctx, token)
+	verifier := flow.CodeVerifier
+	if flow.CodeChallenge != "" {
+		if err := authenticator.VerifyChallenge(req.CodeVerifier, flow.CodeChallenge); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		verifier = req.CodeVerifier
+	}
+
+	token, err := t.Auth.Exchange(ctx, req.Code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	idToken, err := t.Auth.VerifyIDToken(ctx, token)
 	if err != nil {
 		return nil, err
 	}
@@ -60,59 +115,279 @@ func (s *Server) Verify(ctx context.Context, req *pb.VerifyRequest) (*pb.VerifyR
 		return nil, err
 	}
 
+	// Keep the directory up to date, the login succeeds either way
+	info, err := t.Auth.UserInfo(ctx, token)
+	if err != nil {
+		log.Printf("userinfo for %s failed: %v", idToken.Subject, err)
+	}
+	if _, err := s.users.Record(ctx, t.ID, profile, info); err != nil {
+		log.Printf("failed to record user %s: %v", idToken.Subject, err)
+	}
+
 	profileJSON, err := json.Marshal(profile)
 	if err != nil {
 		return nil, err
 	}
 
 	return &pb.VerifyResponse{
-		AccessToken: token.AccessToken,
-		IdToken:     token.Extra("id_token").(string),
-		Profile:     string(profileJSON),
+		AccessToken:  token.AccessToken,
+		IdToken:      token.Extra("id_token").(string),
+		Profile:      string(profileJSON),
+		RedirectUrl:  flow.RedirectURL,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    expiresAt(token),
+	}, nil
+}
+
+func (s *Server) RefreshToken(ctx context.Context, req *pb.RefreshTokenRequest) (*pb.RefreshTokenResponse, error) {
+	t, err := s.tenant(req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := t.Auth.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		return nil, refreshError(err)
+	}
+
+	return &pb.RefreshTokenResponse{
+		AccessToken:  token.AccessToken,
+		IdToken:      authenticator.IDToken(token),
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    expiresAt(token),
 	}, nil
 }
 
 func (s *Server) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
-	logoutURL := fmt.Sprintf("https://%s/v2/logout?returnTo=%s&client_id=%s",
-		os.Getenv("AUTH0_DOMAIN"),
-		url.QueryEscape(req.ReturnUrl),
-		os.Getenv("AUTH0_CLIENT_ID"))
+	t, err := s.tenant(req.TenantId)
+	if err != nil {
+		return nil, err
+	}
 
-	return &pb.LogoutResponse{LogoutUrl: logoutURL}, nil
+	returnURL := req.ReturnUrl
+	if returnURL != "" {
+		if returnURL, err = s.redirects.Check(t.ID, returnURL); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	// Logging out should not fail because the provider cannot revoke
+	revoked := true
+	if err := s.tenants.RevokeTokens(ctx, t.ID, req.AccessToken, req.RefreshToken); err != nil {
+		log.Printf("logout: revoking tokens: %v", err)
+		revoked = false
+	}
+
+	// return_url belongs to the client, which is where a state would have to
+	// be checked, so none is sent
+	logoutURL, err := t.Auth.LogoutURL(returnURL, req.IdTokenHint, "")
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to build logout URL: %v", err)
+	}
+
+	return &pb.LogoutResponse{LogoutUrl: logoutURL, TokensRevoked: revoked}, nil
 }
 
 func (s *Server) VerifyToken(ctx context.Context, req *pb.VerifyTokenRequest) (*pb.VerifyTokenResponse, error) {
-	// Parse and verify the token
-	token, err := s.auth.Provider.Verifier(&oidc.Config{
-		ClientID: s.auth.ClientID,
-	}).Verify(ctx, req.Token)
-
+	// Parse and verify the token with the provider of the tenant that issued it
+	principal, err := s.tenants.Authenticate(ctx, req.Token, req.TenantId)
 	if err != nil {
 		return &pb.VerifyTokenResponse{
 			IsValid: false,
 		}, nil
 	}
 
-	// Extract claims
-	var claims map[string]interface{}
-	if err := token.Claims(&claims); err != nil {
-		return nil, status.Errorf(codes.Internal, "failed to parse claims: %v", err)
+	rawClaims, err := structpb.NewStruct(principal.Claims)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to convert claims: %v", err)
 	}
 
-	// Convert claims to string map
+	// Convert claims to string map, JSON encoding everything that is not a string
 	stringClaims := make(map[string]string)
-	for k, v := range claims {
+	for k, v := range principal.Claims {
 		if str, ok := v.(string); ok {
 			stringClaims[k] = str
-		} else {
-			// Convert non-string values to string representation
-			stringClaims[k] = fmt.Sprintf("%v", v)
+		} else if encoded, err := json.Marshal(v); err == nil {
+			stringClaims[k] = string(encoded)
 		}
 	}
 
 	return &pb.VerifyTokenResponse{
-		IsValid: true,
-		UserId:  claims["sub"].(string),
-		Claims:  stringClaims,
+		IsValid:       true,
+		UserId:        principal.Subject,
+		Claims:        stringClaims,
+		TokenKind:     tokenKind(principal.Kind),
+		TenantId:      principal.TenantID,
+		Roles:         principal.Roles,
+		Permissions:   principal.Permissions,
+		Scopes:        principal.Scopes,
+		Email:         principal.Email,
+		EmailVerified: principal.EmailVerified,
+		ExpiresAt:     timestamp(principal.ExpiresAt),
+		IssuedAt:      timestamp(principal.IssuedAt),
+		RawClaims:     rawClaims,
+		PrincipalType: principalType(principal.Type),
+	}, nil
+}
+
+func (s *Server) IssueServiceToken(ctx context.Context, req *pb.IssueServiceTokenRequest) (*pb.IssueServiceTokenResponse, error) {
+	t, err := s.tenant(req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.services.Issue(ctx, t, service.Request{
+		ClientID:     req.ClientId,
+		ClientSecret: req.ClientSecret,
+		Audience:     req.Audience,
+		Scopes:       req.Scopes,
+	})
+	if err != nil {
+		return nil, serviceError(err)
+	}
+
+	return &pb.IssueServiceTokenResponse{
+		AccessToken: token.AccessToken,
+		TokenType:   token.Type(),
+		ExpiresAt:   expiresAt(token),
 	}, nil
 }
+
+func (s *Server) RevokeToken(ctx context.Context, req *pb.RevokeTokenRequest) (*pb.RevokeTokenResponse, error) {
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+	if err := s.tenants.Revoke(ctx, req.Token, req.TenantId); err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to revoke token: %v", err)
+	}
+	return &pb.RevokeTokenResponse{}, nil
+}
+
+// Permissions needed to sign out other users and whole tenants.
+const (
+	revokeSessions = "sessions:revoke"
+	revokeTenants  = "tenants:revoke"
+)
+
+// RevokeAllForUser lets users sign themselves out everywhere. Signing out
+// anyone else needs the sessions:revoke permission.
+func (s *Server) RevokeAllForUser(ctx context.Context, req *pb.RevokeAllForUserRequest) (*pb.RevokeAllResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	caller, err := s.caller(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Subject != req.UserId {
+		decision := s.policy.Check(authz.SubjectOf(caller), revokeSessions, "")
+		if !decision.Allowed {
+			return nil, status.Error(codes.PermissionDenied, decision.Reason)
+		}
+	}
+	return s.revokeAll(ctx, caller.TenantID, req.UserId)
+}
+
+func (s *Server) RevokeAllForTenant(ctx context.Context, req *pb.RevokeAllForTenantRequest) (*pb.RevokeAllResponse, error) {
+	caller, err := s.authorize(ctx, req.TenantId, revokeTenants)
+	if err != nil {
+		return nil, err
+	}
+	return s.revokeAll(ctx, caller.TenantID, "")
+}
+
+func (s *Server) revokeAll(ctx context.Context, tenantID, subject string) (*pb.RevokeAllResponse, error) {
+	notBefore, err := s.tenants.RevokeAll(ctx, tenantID, subject)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to revoke tokens: %v", err)
+	}
+	return &pb.RevokeAllResponse{NotBefore: timestamppb.New(notBefore)}, nil
+}
+
+// tenant looks up a tenant by id, the default tenant when id is empty.
+func (s *Server) tenant(id string) (*tenant.Tenant, error) {
+	t, err := s.tenants.Get(id)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return t, nil
+}
+
+func tokenKind(kind authenticator.TokenKind) pb.TokenKind {
+	switch kind {
+	case authenticator.KindIDToken:
+		return pb.TokenKind_TOKEN_KIND_ID_TOKEN
+	case authenticator.KindAccessToken:
+		return pb.TokenKind_TOKEN_KIND_ACCESS_TOKEN
+	case authenticator.KindAPIKey:
+		return pb.TokenKind_TOKEN_KIND_API_KEY
+	case authenticator.KindPersonalToken:
+		return pb.TokenKind_TOKEN_KIND_PERSONAL_ACCESS_TOKEN
+	default:
+		return pb.TokenKind_TOKEN_KIND_UNSPECIFIED
+	}
+}
+
+func principalType(t string) pb.PrincipalType {
+	switch t {
+	case identity.TypeUser:
+		return pb.PrincipalType_PRINCIPAL_TYPE_USER
+	case identity.TypeService:
+		return pb.PrincipalType_PRINCIPAL_TYPE_SERVICE
+	case identity.TypeAPIKey:
+		return pb.PrincipalType_PRINCIPAL_TYPE_API_KEY
+	default:
+		return pb.PrincipalType_PRINCIPAL_TYPE_UNSPECIFIED
+	}
+}
+
+// serviceError maps a failed service token request to a gRPC status.
+func serviceError(err error) error {
+	var re *oauth2.RetrieveError
+	switch {
+	case errors.Is(err, service.ErrUnauthorized):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, service.ErrNotAllowed):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.As(err, &re) && re.Response != nil && re.Response.StatusCode < http.StatusInternalServerError:
+		return status.Errorf(codes.FailedPrecondition, "identity provider refused the service: %v", err)
+	default:
+		return status.Errorf(codes.Unavailable, "identity provider is unavailable: %v", err)
+	}
+}
+
+// refreshError maps a failed refresh to a gRPC status.
+func refreshError(err error) error {
+	switch {
+	case errors.Is(err, authenticator.ErrRefreshRejected):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, authenticator.ErrClientRejected):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, authenticator.ErrRateLimited):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+func expiresAt(token *oauth2.Token) *timestamppb.Timestamp {
+	return timestamp(token.Expiry)
+}
+
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// stateError maps a state store error to a gRPC status.
+func stateError(err error) error {
+	switch {
+	case errors.Is(err, state.ErrExpired), errors.Is(err, state.ErrUsed):
+		return status.Errorf(codes.FailedPrecondition, "invalid state: %v", err)
+	default:
+		return status.Errorf(codes.InvalidArgument, "invalid state: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	pb "authentication/src/gen/proto"
+	"authentication/src/platform/authz"
+	"authentication/src/platform/directory"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// readUsers is the permission needed to look up users other than oneself.
+const readUsers = "users:read"
+
+// maxProfileBatch caps BatchGetPublicProfiles, a page of reviews is well
+// below it.
+const maxProfileBatch = 100
+
+func (s *Server) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	caller, err := s.caller(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+	if caller.Subject != req.UserId {
+		decision := s.policy.Check(authz.SubjectOf(caller), readUsers, "")
+		if !decision.Allowed {
+			return nil, status.Error(codes.PermissionDenied, decision.Reason)
+		}
+	}
+
+	u, memberships, err := s.users.Get(ctx, caller.TenantID, req.UserId)
+	if errors.Is(err, directory.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to look up user: %v", err)
+	}
+
+	res := &pb.User{
+		Id:            u.ID,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		Name:          u.Name,
+		Picture:       u.Picture,
+		CreatedAt:     timestamp(u.CreatedAt),
+		LastLoginAt:   timestamp(u.LastLoginAt),
+		Pseudonymous:  u.Pseudonymous,
+	}
+	for _, m := range memberships {
+		// The same sub at another provider is somebody else
+		if !s.tenants.SameIssuer(caller.TenantID, m.TenantID) {
+			continue
+		}
+		res.Memberships = append(res.Memberships, &pb.TenantMembership{
+			TenantId:    m.TenantID,
+			CreatedAt:   timestamp(m.CreatedAt),
+			LastLoginAt: timestamp(m.LastLoginAt),
+		})
+	}
+	return res, nil
+}
+
+func (s *Server) BatchGetPublicProfiles(ctx context.Context, req *pb.BatchGetPublicProfilesRequest) (*pb.BatchGetPublicProfilesResponse, error) {
+	if len(req.UserIds) > maxProfileBatch {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d users per call", maxProfileBatch)
+	}
+	caller, err := s.caller(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := s.users.PublicProfiles(ctx, caller.TenantID, req.UserIds)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to look up profiles: %v", err)
+	}
+
+	res := &pb.BatchGetPublicProfilesResponse{Profiles: make([]*pb.PublicProfile, len(profiles))}
+	for i, p := range profiles {
+		res.Profiles[i] = publicProfile(p)
+	}
+	return res, nil
+}
+
+func (s *Server) UpdateProfilePrivacy(ctx context.Context, req *pb.UpdateProfilePrivacyRequest) (*pb.PublicProfile, error) {
+	caller, err := s.caller(ctx, req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.users.SetPseudonymous(ctx, caller.TenantID, caller.Subject, req.Pseudonymous)
+	if errors.Is(err, directory.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to update privacy: %v", err)
+	}
+	return publicProfile(u.Public()), nil
+}
+
+func publicProfile(p directory.PublicProfile) *pb.PublicProfile {
+	return &pb.PublicProfile{
+		UserId:       p.UserID,
+		DisplayName:  p.DisplayName,
+		Picture:      p.Picture,
+		Pseudonymous: p.Pseudonymous,
+	}
+}
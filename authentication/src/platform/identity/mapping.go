@@ -0,0 +1,78 @@
+package identity
+
+import (
+	"os"
+	"strings"
+)
+
+// Mapping names the claims typed principal fields are read from. Names that
+// are not URLs are looked up under Namespace first, so namespaced custom
+// claims such as Auth0's "https://example.com/roles" work alongside plain ones.
+type Mapping struct {
+	Namespace     string `json:"namespace"`
+	Tenant        string `json:"tenant"`
+	Roles         string `json:"roles"`
+	Permissions   string `json:"permissions"`
+	Email         string `json:"email"`
+	EmailVerified string `json:"email_verified"`
+}
+
+// DefaultMapping reads the standard and commonly used claim names.
+func DefaultMapping() Mapping {
+	return Mapping{
+		Tenant:        "tenant_id",
+		Roles:         "roles",
+		Permissions:   "permissions",
+		Email:         "email",
+		EmailVerified: "email_verified",
+	}
+}
+
+// MappingFromEnv reads CLAIMS_NAMESPACE and the CLAIM_* overrides.
+func MappingFromEnv() Mapping {
+	m := DefaultMapping()
+	m.Namespace = os.Getenv("CLAIMS_NAMESPACE")
+	m.Tenant = or(os.Getenv("CLAIM_TENANT"), m.Tenant)
+	m.Roles = or(os.Getenv("CLAIM_ROLES"), m.Roles)
+	m.Permissions = or(os.Getenv("CLAIM_PERMISSIONS"), m.Permissions)
+	return m
+}
+
+// Merge returns m with the fields set in override replaced.
+func (m Mapping) Merge(override Mapping) Mapping {
+	m.Namespace = or(override.Namespace, m.Namespace)
+	m.Tenant = or(override.Tenant, m.Tenant)
+	m.Roles = or(override.Roles, m.Roles)
+	m.Permissions = or(override.Permissions, m.Permissions)
+	m.Email = or(override.Email, m.Email)
+	m.EmailVerified = or(override.EmailVerified, m.EmailVerified)
+	return m
+}
+
+// TenantOf returns the tenant named in claims, or "" if there is none.
+func (m Mapping) TenantOf(claims map[string]interface{}) string {
+	value, _ := m.lookup(claims, m.Tenant)
+	tenantID, _ := value.(string)
+	return tenantID
+}
+
+// lookup finds the claim called name, trying the namespaced name first.
+func (m Mapping) lookup(claims map[string]interface{}, name string) (interface{}, bool) {
+	if name == "" {
+		return nil, false
+	}
+	if m.Namespace != "" && !strings.Contains(name, "://") {
+		if value, ok := claims[m.Namespace+name]; ok {
+			return value, true
+		}
+	}
+	value, ok := claims[name]
+	return value, ok
+}
+
+func or(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,142 @@
+package identity
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"authentication/src/platform/authenticator"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// Principal types tell users from machines.
+const (
+	TypeUser    = "user"
+	TypeService = "service"
+	TypeAPIKey  = "api_key"
+)
+
+// Principal is the verified caller behind a token.
+type Principal struct {
+	Subject string
+	// TokenID is the token's jti, if it has one.
+	TokenID  string
+	TenantID string
+	// Type is TypeUser, TypeService or TypeAPIKey.
+	Type          string
+	Scopes        []string
+	Roles         []string
+	Permissions   []string
+	Email         string
+	EmailVerified bool
+	Kind          authenticator.TokenKind
+	IssuedAt      time.Time
+	ExpiresAt     time.Time
+	// Claims holds every claim of the token as decoded from JSON.
+	Claims map[string]interface{}
+}
+
+// FromToken builds a *Principal from a verified token, reading custom claims
+// as described by m.
+func FromToken(token *oidc.IDToken, kind authenticator.TokenKind, m Mapping) (*Principal, error) {
+	var claims map[string]interface{}
+	if err := token.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	p := &Principal{
+		Subject:     token.Subject,
+		Type:        principalType(token.Subject, claims),
+		Scopes:      scopes(claims),
+		Roles:       normalize(stringsClaim(m, claims, m.Roles)),
+		Permissions: normalize(stringsClaim(m, claims, m.Permissions)),
+		Kind:        kind,
+		IssuedAt:    token.IssuedAt,
+		ExpiresAt:   token.Expiry,
+		Claims:      claims,
+	}
+	p.TokenID, _ = claims["jti"].(string)
+	p.TenantID = m.TenantOf(claims)
+	if value, ok := m.lookup(claims, m.Email); ok {
+		p.Email, _ = value.(string)
+	}
+	if value, ok := m.lookup(claims, m.EmailVerified); ok {
+		p.EmailVerified, _ = value.(bool)
+	}
+	return p, nil
+}
+
+// HasScope reports whether the token was granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// HasRole reports whether the principal has role.
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// HasPermission reports whether the principal was granted permission.
+func (p *Principal) HasPermission(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
+
+// principalType recognises tokens from the client credentials grant. Auth0
+// marks them with gty and a "@clients" subject, other providers use the
+// client ID as the subject.
+func principalType(subject string, claims map[string]interface{}) string {
+	if gty, _ := claims["gty"].(string); gty == "client-credentials" {
+		return TypeService
+	}
+	if strings.HasSuffix(subject, "@clients") {
+		return TypeService
+	}
+	for _, name := range []string{"client_id", "azp"} {
+		if clientID, _ := claims[name].(string); clientID != "" && clientID == subject {
+			return TypeService
+		}
+	}
+	return TypeUser
+}
+
+// scopes reads the space delimited scope claim, or the scp array some
+// providers use instead.
+func scopes(claims map[string]interface{}) []string {
+	if scope, ok := claims["scope"].(string); ok && scope != "" {
+		return strings.Fields(scope)
+	}
+	return stringsClaim(Mapping{}, claims, "scp")
+}
+
+// stringsClaim reads a claim that holds either an array of strings or a
+// single string.
+func stringsClaim(m Mapping, claims map[string]interface{}, name string) []string {
+	value, _ := m.lookup(claims, name)
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// normalize trims, sorts and deduplicates values.
+func normalize(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
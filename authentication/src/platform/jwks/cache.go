@@ -0,0 +1,306 @@
+package jwks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+var (
+	ErrUnknownKey = errors.New("jwks: no key matches the token's kid")
+	ErrStale      = errors.New("jwks: cached keys are too old and the key set cannot be refreshed")
+)
+
+// metrics are published under /debug/vars on METRICS_ADDR as "jwks", one
+// map per key set URL so the counters of different issuers stay apart.
+var (
+	metrics   = expvar.NewMap("jwks")
+	metricsMu sync.Mutex
+)
+
+// metricsFor returns the metrics of the key set at url. Caches of the same
+// key set share them.
+func metricsFor(url string) *expvar.Map {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	if m, ok := metrics.Get(url).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map)
+	metrics.Set(url, m)
+	return m
+}
+
+// Options tune how a *Cache refreshes its keys. Zero values use the defaults.
+type Options struct {
+	// MinRefresh is the shortest time keys are cached, whatever Cache-Control says.
+	MinRefresh time.Duration
+	// MaxRefresh is the longest time keys are cached without a refresh.
+	MaxRefresh time.Duration
+	// MaxStale is how long cached keys keep being served while refreshes fail.
+	MaxStale time.Duration
+	// UnknownKIDInterval is the minimum time between refetches triggered by
+	// tokens with a kid we do not know.
+	UnknownKIDInterval time.Duration
+	// Client is used to fetch the key set.
+	Client *http.Client
+}
+
+func (o *Options) defaults() {
+	if o.MinRefresh <= 0 {
+		o.MinRefresh = 5 * time.Minute
+	}
+	if o.MaxRefresh <= 0 {
+		o.MaxRefresh = time.Hour
+	}
+	if o.MaxRefresh < o.MinRefresh {
+		o.MaxRefresh = o.MinRefresh
+	}
+	if o.MaxStale <= 0 {
+		o.MaxStale = 24 * time.Hour
+	}
+	if o.UnknownKIDInterval <= 0 {
+		o.UnknownKIDInterval = 30 * time.Second
+	}
+	if o.Client == nil {
+		o.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+}
+
+// Cache is a local copy of a provider's JSON Web Key Set. It refreshes in the
+// background as Cache-Control allows, refetches when a token names a kid it
+// does not know and keeps serving its keys while the provider is down, up to
+// Options.MaxStale. It implements oidc.KeySet.
+type Cache struct {
+	url     string
+	opts    Options
+	now     func() time.Time
+	metrics *expvar.Map
+
+	mu          sync.RWMutex
+	keys        jose.JSONWebKeySet
+	fetchedAt   time.Time
+	nextRefresh time.Time
+	lastKIDPoll time.Time
+
+	// fetch serializes refreshes so concurrent callers share one request.
+	fetch sync.Mutex
+}
+
+// New instantiates a *Cache for the key set at url. Keys are fetched lazily
+// unless Start is called.
+func New(url string, opts Options) *Cache {
+	opts.defaults()
+	return &Cache{url: url, opts: opts, now: time.Now, metrics: metricsFor(url)}
+}
+
+// Start fetches the key set and keeps it fresh until ctx is done.
+func (c *Cache) Start(ctx context.Context) {
+	if err := c.Refresh(ctx); err != nil {
+		log.Printf("jwks: initial fetch of %s failed: %v", c.url, err)
+	}
+	go c.loop(ctx)
+}
+
+func (c *Cache) loop(ctx context.Context) {
+	retry := time.Second
+	for {
+		c.mu.RLock()
+		wait := c.nextRefresh.Sub(c.now())
+		c.mu.RUnlock()
+		if wait < retry {
+			wait = retry
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if err := c.Refresh(ctx); err != nil {
+			log.Printf("jwks: refresh of %s failed: %v", c.url, err)
+			retry = min(retry*2, c.opts.MinRefresh)
+			continue
+		}
+		retry = time.Second
+	}
+}
+
+// VerifySignature verifies jwt with the cached keys and returns its payload.
+func (c *Cache) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: malformed jwt: %v", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, errors.New("jwks: expected exactly one signature")
+	}
+	kid := jws.Signatures[0].Header.KeyID
+
+	keys, err := c.keysFor(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key.Use == "enc" {
+			continue
+		}
+		if payload, err := jws.Verify(&key); err == nil {
+			return payload, nil
+		}
+	}
+	return nil, errors.New("jwks: failed to verify signature")
+}
+
+// keysFor returns the candidate keys for kid, refetching the key set when
+// the kid is unknown and the rate limit allows it.
+func (c *Cache) keysFor(ctx context.Context, kid string) ([]jose.JSONWebKey, error) {
+	keys, fetchedAt := c.lookup(kid)
+	if len(keys) > 0 {
+		if c.now().Sub(fetchedAt) > c.opts.MaxStale {
+			c.metrics.Add("stale_rejections", 1)
+			return nil, ErrStale
+		}
+		return keys, nil
+	}
+
+	if !c.allowKIDPoll(fetchedAt) {
+		c.metrics.Add("unknown_kid_throttled", 1)
+		return nil, ErrUnknownKey
+	}
+	c.metrics.Add("unknown_kid_refetches", 1)
+
+	if err := c.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	if keys, _ = c.lookup(kid); len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+	return keys, nil
+}
+
+func (c *Cache) lookup(kid string) ([]jose.JSONWebKey, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if kid == "" {
+		return c.keys.Keys, c.fetchedAt
+	}
+	return c.keys.Key(kid), c.fetchedAt
+}
+
+// allowKIDPoll rate limits refetches for unknown kids. The very first fetch
+// is always allowed.
+func (c *Cache) allowKIDPoll(fetchedAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if !fetchedAt.IsZero() && now.Sub(c.lastKIDPoll) < c.opts.UnknownKIDInterval {
+		return false
+	}
+	c.lastKIDPoll = now
+	return true
+}
+
+// Refresh fetches the key set now. Callers that arrive while a fetch is in
+// flight wait for it instead of starting another one.
+func (c *Cache) Refresh(ctx context.Context) error {
+	c.mu.RLock()
+	before := c.fetchedAt
+	c.mu.RUnlock()
+
+	c.fetch.Lock()
+	defer c.fetch.Unlock()
+
+	// Someone else refreshed while we waited
+	c.mu.RLock()
+	refreshed := c.fetchedAt.After(before)
+	c.mu.RUnlock()
+	if refreshed {
+		return nil
+	}
+
+	c.metrics.Add("refreshes", 1)
+	keys, maxAge, err := c.download(ctx)
+	if err != nil {
+		c.metrics.Add("refresh_failures", 1)
+		return err
+	}
+
+	now := c.now()
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = now
+	c.nextRefresh = now.Add(maxAge)
+	c.mu.Unlock()
+
+	c.metrics.Set("last_refresh_unix", intVar(now.Unix()))
+	return nil
+}
+
+func (c *Cache) download(ctx context.Context) (jose.JSONWebKeySet, time.Duration, error) {
+	var keys jose.JSONWebKeySet
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return keys, 0, err
+	}
+	resp, err := c.opts.Client.Do(req)
+	if err != nil {
+		return keys, 0, fmt.Errorf("jwks: fetching keys: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return keys, 0, fmt.Errorf("jwks: reading keys: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return keys, 0, fmt.Errorf("jwks: fetching keys: %s", resp.Status)
+	}
+	if err := json.Unmarshal(body, &keys); err != nil {
+		return keys, 0, fmt.Errorf("jwks: decoding keys: %w", err)
+	}
+	if len(keys.Keys) == 0 {
+		return keys, 0, errors.New("jwks: key set is empty")
+	}
+
+	return keys, c.maxAge(resp.Header.Get("Cache-Control")), nil
+}
+
+// maxAge reads max-age from a Cache-Control header, bounded by the options.
+func (c *Cache) maxAge(header string) time.Duration {
+	age := c.opts.MaxRefresh
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-cache", "no-store":
+			return c.opts.MinRefresh
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil {
+				age = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return max(c.opts.MinRefresh, min(age, c.opts.MaxRefresh))
+}
+
+func intVar(v int64) *expvar.Int {
+	i := new(expvar.Int)
+	i.Set(v)
+	return i
+}
@@ -0,0 +1,263 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// provider is a JWKS endpoint whose keys can be rotated and which can be
+// made to fail.
+type provider struct {
+	mu           sync.Mutex
+	keys         []jose.JSONWebKey
+	cacheControl string
+	failing      bool
+	fetches      int
+}
+
+func (p *provider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.fetches++
+	if p.failing {
+		http.Error(w, "down", http.StatusInternalServerError)
+		return
+	}
+	if p.cacheControl != "" {
+		w.Header().Set("Cache-Control", p.cacheControl)
+	}
+	json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: p.keys})
+}
+
+func (p *provider) publish(keys ...*signingKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keys = nil
+	for _, k := range keys {
+		p.keys = append(p.keys, k.public())
+	}
+}
+
+func (p *provider) fail() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failing = true
+}
+
+func (p *provider) fetchCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.fetches
+}
+
+type signingKey struct {
+	kid string
+	key *ecdsa.PrivateKey
+}
+
+func newKey(t *testing.T, kid string) *signingKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signingKey{kid: kid, key: key}
+}
+
+func (k *signingKey) public() jose.JSONWebKey {
+	return jose.JSONWebKey{Key: &k.key.PublicKey, KeyID: k.kid, Algorithm: string(jose.ES256), Use: "sig"}
+}
+
+func (k *signingKey) sign(t *testing.T) string {
+	t.Helper()
+	opts := (&jose.SignerOptions{}).WithHeader(jose.HeaderKey("kid"), k.kid)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: k.key}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := signer.Sign([]byte(`{"sub":"user"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// clock is a manually advanced time source.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newCache(t *testing.T, p *provider, opts Options) (*Cache, *clock) {
+	t.Helper()
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+
+	c := New(server.URL, opts)
+	clk := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c.now = clk.Now
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatalf("initial refresh: %v", err)
+	}
+	return c, clk
+}
+
+func TestUnknownKIDTriggersRefetch(t *testing.T) {
+	old, rotated := newKey(t, "old"), newKey(t, "new")
+	p := &provider{}
+	p.publish(old)
+	c, _ := newCache(t, p, Options{})
+
+	if _, err := c.VerifySignature(context.Background(), old.sign(t)); err != nil {
+		t.Fatalf("token of the cached key: %v", err)
+	}
+
+	// The provider rotates, tokens with the new kid have to verify at once
+	p.publish(old, rotated)
+	if _, err := c.VerifySignature(context.Background(), rotated.sign(t)); err != nil {
+		t.Fatalf("token of the rotated key: %v", err)
+	}
+	if got := p.fetchCount(); got != 2 {
+		t.Errorf("fetches = %d, want 2", got)
+	}
+}
+
+func TestUnknownKIDRefetchIsRateLimited(t *testing.T) {
+	known, unknown := newKey(t, "known"), newKey(t, "unknown")
+	p := &provider{}
+	p.publish(known)
+	c, clk := newCache(t, p, Options{UnknownKIDInterval: 30 * time.Second})
+
+	token := unknown.sign(t)
+	if _, err := c.VerifySignature(context.Background(), token); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("err = %v, want ErrUnknownKey", err)
+	}
+	if got := p.fetchCount(); got != 2 {
+		t.Fatalf("fetches after the first unknown kid = %d, want 2", got)
+	}
+
+	// Within the interval unknown kids do not reach the provider
+	clk.Advance(10 * time.Second)
+	for range 5 {
+		if _, err := c.VerifySignature(context.Background(), token); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("err = %v, want ErrUnknownKey", err)
+		}
+	}
+	if got := p.fetchCount(); got != 2 {
+		t.Fatalf("fetches within the interval = %d, want 2", got)
+	}
+
+	clk.Advance(30 * time.Second)
+	if _, err := c.VerifySignature(context.Background(), token); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("err = %v, want ErrUnknownKey", err)
+	}
+	if got := p.fetchCount(); got != 3 {
+		t.Errorf("fetches after the interval = %d, want 3", got)
+	}
+}
+
+func TestCacheControlMaxAge(t *testing.T) {
+	opts := Options{MinRefresh: time.Minute, MaxRefresh: time.Hour}
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"public, max-age=600", 10 * time.Minute},
+		{"max-age=5", time.Minute},
+		{"max-age=86400", time.Hour},
+		{"no-cache", time.Minute},
+		{"", time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			p := &provider{cacheControl: tt.header}
+			p.publish(newKey(t, "key"))
+			c, clk := newCache(t, p, opts)
+
+			if got := c.nextRefresh.Sub(clk.Now()); got != tt.want {
+				t.Errorf("next refresh in %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServesCachedKeysUntilMaxStale(t *testing.T) {
+	key := newKey(t, "key")
+	p := &provider{cacheControl: "max-age=300"}
+	p.publish(key)
+	c, clk := newCache(t, p, Options{MaxStale: time.Hour})
+	token := key.sign(t)
+
+	p.fail()
+	clk.Advance(10 * time.Minute)
+	if err := c.Refresh(context.Background()); err == nil {
+		t.Fatal("refresh against a failing provider succeeded")
+	}
+	if _, err := c.VerifySignature(context.Background(), token); err != nil {
+		t.Fatalf("cached key while the provider fails: %v", err)
+	}
+
+	clk.Advance(time.Hour)
+	if _, err := c.VerifySignature(context.Background(), token); !errors.Is(err, ErrStale) {
+		t.Errorf("err = %v, want ErrStale", err)
+	}
+}
+
+func TestMetricsArePerKeySet(t *testing.T) {
+	up, down := &provider{}, &provider{}
+	up.publish(newKey(t, "up"))
+	down.publish(newKey(t, "down"))
+	a, _ := newCache(t, up, Options{})
+	b, _ := newCache(t, down, Options{})
+
+	down.fail()
+	if err := b.Refresh(context.Background()); err == nil {
+		t.Fatal("refresh against a failing provider succeeded")
+	}
+
+	counter := func(url, name string) int64 {
+		m, ok := metrics.Get(url).(*expvar.Map)
+		if !ok {
+			t.Fatalf("no metrics for %s", url)
+		}
+		v, _ := m.Get(name).(*expvar.Int)
+		if v == nil {
+			return 0
+		}
+		return v.Value()
+	}
+	if got := counter(a.url, "refreshes"); got != 1 {
+		t.Errorf("refreshes of %s = %d, want 1", a.url, got)
+	}
+	if got := counter(a.url, "refresh_failures"); got != 0 {
+		t.Errorf("refresh_failures of %s = %d, want 0", a.url, got)
+	}
+	if got := counter(b.url, "refreshes"); got != 2 {
+		t.Errorf("refreshes of %s = %d, want 2", b.url, got)
+	}
+	if got := counter(b.url, "refresh_failures"); got != 1 {
+		t.Errorf("refresh_failures of %s = %d, want 1", b.url, got)
+	}
+}
@@ -0,0 +1,91 @@
+package management
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Fake keeps users in memory, for tests and local development.
+type Fake struct {
+	mu    sync.Mutex
+	users []User
+	// Verifications lists the users verification emails were sent to.
+	Verifications []string
+}
+
+// NewFake instantiates a *Fake with users.
+func NewFake(users ...User) *Fake {
+	return &Fake{users: slices.Clone(users)}
+}
+
+func (f *Fake) Search(ctx context.Context, q Query) (*Page, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	text := strings.TrimSpace(q.Text)
+	var matches []User
+	for _, u := range f.users {
+		if text == "" || u.Email == text || u.Name == text || u.ID == text {
+			matches = append(matches, u)
+		}
+	}
+
+	size := perPage(q)
+	start := min(max(q.Page, 0)*size, len(matches))
+	end := min(start+size, len(matches))
+	return &Page{
+		Users:   slices.Clone(matches[start:end]),
+		Total:   len(matches),
+		Page:    q.Page,
+		PerPage: size,
+	}, nil
+}
+
+func (f *Fake) Block(ctx context.Context, id string) error {
+	return f.update(id, func(u *User) { u.Blocked = true })
+}
+
+func (f *Fake) Unblock(ctx context.Context, id string) error {
+	return f.update(id, func(u *User) { u.Blocked = false })
+}
+
+func (f *Fake) Delete(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	f.users = slices.Delete(f.users, i, i+1)
+	return nil
+}
+
+func (f *Fake) ResendVerificationEmail(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.index(id) < 0 {
+		return ErrNotFound
+	}
+	f.Verifications = append(f.Verifications, id)
+	return nil
+}
+
+func (f *Fake) update(id string, change func(*User)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i := f.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	change(&f.users[i])
+	return nil
+}
+
+func (f *Fake) index(id string) int {
+	return slices.IndexFunc(f.users, func(u User) bool { return u.ID == id })
+}
@@ -0,0 +1,71 @@
+package management
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("user not found")
+	// ErrRateLimited means the provider kept rejecting us after backing off.
+	ErrRateLimited = errors.New("management API rate limit exceeded")
+	// ErrUnavailable means the provider could not be reached or failed.
+	ErrUnavailable = errors.New("management API is unavailable")
+)
+
+// MaxPerPage caps Query.PerPage.
+const MaxPerPage = 100
+
+// User is an account at the identity provider.
+type User struct {
+	ID            string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+	Blocked       bool
+	CreatedAt     time.Time
+	LastLoginAt   time.Time
+	LoginsCount   int
+}
+
+// Query selects users. Text matches the email, name or user ID exactly,
+// empty Text lists everyone. Page starts at 0.
+type Query struct {
+	Text    string
+	Page    int
+	PerPage int
+}
+
+// Page is one page of search results.
+type Page struct {
+	Users   []User
+	Total   int
+	Page    int
+	PerPage int
+}
+
+// Users administers the accounts of one tenant at its identity provider.
+type Users interface {
+	Search(ctx context.Context, q Query) (*Page, error)
+	// Block stops the user from logging in, Unblock lets them again.
+	Block(ctx context.Context, id string) error
+	Unblock(ctx context.Context, id string) error
+	// Delete removes the user's account for good.
+	Delete(ctx context.Context, id string) error
+	// ResendVerificationEmail sends the user another email verification link.
+	ResendVerificationEmail(ctx context.Context, id string) error
+}
+
+// perPage clamps the page size requested in q.
+func perPage(q Query) int {
+	switch {
+	case q.PerPage <= 0:
+		return 50
+	case q.PerPage > MaxPerPage:
+		return MaxPerPage
+	default:
+		return q.PerPage
+	}
+}
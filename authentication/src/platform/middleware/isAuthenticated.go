@@ -2,17 +2,41 @@ package middleware
 
 import (
 	"net/http"
+	"net/url"
+
+	"authentication/src/platform/session"
 
-	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
+// SessionKey is the gin context key IsAuthenticated stores the session under.
+const SessionKey = "session"
+
 // IsAuthenticated is a middleware that checks if
 // the user has already been authenticated previously.
-func IsAuthenticated(ctx *gin.Context) {
-	if sessions.Default(ctx).Get("profile") == nil {
-		ctx.Redirect(http.StatusSeeOther, "/")
-	} else {
+// Browsers without a session of the request's tenant are sent to log in
+// and brought back afterwards. It has to run after Tenant.
+func IsAuthenticated(sessions *session.Manager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		s, err := sessions.Get(ctx.Writer, ctx.Request)
+		if err != nil || s.TenantID != GetTenant(ctx).ID {
+			login := TenantPath(ctx, "/login") + "?return_to=" + url.QueryEscape(ctx.Request.URL.RequestURI())
+			ctx.Redirect(http.StatusSeeOther, login)
+			ctx.Abort()
+			return
+		}
+
+		ctx.Set(SessionKey, s)
 		ctx.Next()
 	}
 }
+
+// GetSession returns the session stored by IsAuthenticated.
+func GetSession(c *gin.Context) (*session.Session, bool) {
+	value, ok := c.Get(SessionKey)
+	if !ok {
+		return nil, false
+	}
+	s, ok := value.(*session.Session)
+	return s, ok
+}
@@ -1,36 +1,164 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
+
+	"authentication/src/platform/authenticator"
+	"authentication/src/platform/identity"
+	"authentication/src/platform/session"
+	"authentication/src/platform/tenant"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AuthRequired() gin.HandlerFunc {
-    return func(c *gin.Context) {
-        authHeader := c.GetHeader("Authorization")
-        if authHeader == "" {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "No authorization header"})
-            c.Abort()
-            return
-        }
-
-        // Extract the token from the Authorization header
-        bearerToken := strings.Split(authHeader, " ")
-        if len(bearerToken) != 2 {
-            c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header"})
-            c.Abort()
-            return
-        }
-
-        token := bearerToken[1]
-
-        // Verify token here if needed
-        // For Auth0, the token is already verified by Auth0
-
-        // Add token claims to context if needed
-        c.Set("token", token)
-        c.Next()
-    }
+// PrincipalKey is the gin context key AuthRequired stores the caller under.
+const PrincipalKey = "principal"
+
+// refreshMargin is how long before expiry session access tokens are refreshed.
+const refreshMargin = 30 * time.Second
+
+// AuthRequired is a middleware that verifies the bearer token against the
+// keys of the request's tenant and stores the caller's *identity.Principal in
+// the context. It has to run after Tenant. Failures are answered as described
+// in RFC 6750.
+//
+// API keys are accepted in X-Api-Key or as "Authorization: ApiKey <key>".
+// With sessions set, requests without credentials may instead use the
+// access token kept in their session (backend-for-frontend mode).
+func AuthRequired(tenants *tenant.Registry, sessions *session.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		var token string
+		switch {
+		case c.GetHeader("X-Api-Key") != "":
+			token = c.GetHeader("X-Api-Key")
+		case authHeader != "":
+			// Extract the token from the Authorization header
+			scheme, bearer, ok := strings.Cut(authHeader, " ")
+			if !ok || (!strings.EqualFold(scheme, "Bearer") && !strings.EqualFold(scheme, "ApiKey")) || bearer == "" {
+				challenge(c, http.StatusBadRequest, "invalid_request", "Malformed authorization header")
+				return
+			}
+			token = bearer
+		case sessions != nil:
+			var err error
+			if token, err = sessionToken(c, sessions); err != nil {
+				challenge(c, http.StatusUnauthorized, "invalid_token", err.Error())
+				return
+			}
+			if token == "" {
+				challenge(c, http.StatusUnauthorized, "", "")
+				return
+			}
+		default:
+			challenge(c, http.StatusUnauthorized, "", "")
+			return
+		}
+
+		// Verify signature, issuer, audience and expiry for this tenant only
+		principal, err := tenants.Authenticate(c.Request.Context(), token, GetTenant(c).ID)
+		if err != nil {
+			challenge(c, http.StatusUnauthorized, "invalid_token", err.Error())
+			return
+		}
+
+		c.Set("token", token)
+		c.Set(PrincipalKey, principal)
+		c.Next()
+	}
+}
+
+// sessionToken returns the access token of the browser's session, refreshed
+// if it is about to expire, or "" without a session. Cookies are sent along
+// with cross-site requests too, so requests that change anything have to
+// carry X-Requested-With, which cross-site forms cannot set.
+func sessionToken(c *gin.Context, sessions *session.Manager) (string, error) {
+	s, err := sessions.Get(c.Writer, c.Request)
+	if err != nil {
+		return "", nil
+	}
+	t := GetTenant(c)
+	if s.TenantID != t.ID {
+		return "", nil
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		if c.GetHeader("X-Requested-With") == "" {
+			return "", errors.New("X-Requested-With header is required")
+		}
+	}
+
+	if s.TokenExpiry.IsZero() || time.Until(s.TokenExpiry) > refreshMargin {
+		return s.AccessToken, nil
+	}
+
+	refreshed, err := refreshes.refresh(c.Request.Context(), t.Auth, s.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+	s.AccessToken = refreshed.AccessToken
+	s.RefreshToken = refreshed.RefreshToken
+	s.TokenExpiry = refreshed.Expiry
+	if idToken := authenticator.IDToken(refreshed); idToken != "" {
+		s.IDToken = idToken
+	}
+	if err := sessions.Update(c.Writer, c.Request, s); err != nil {
+		return "", err
+	}
+	return s.AccessToken, nil
+}
+
+// RequireScope is a middleware that only lets principals with scope through.
+// It has to run after AuthRequired.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok || !principal.HasScope(scope) {
+			challenge(c, http.StatusForbidden, "insufficient_scope", "The "+scope+" scope is required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetPrincipal returns the caller stored by AuthRequired.
+func GetPrincipal(c *gin.Context) (*identity.Principal, bool) {
+	value, ok := c.Get(PrincipalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*identity.Principal)
+	return principal, ok
+}
+
+// challenge aborts with a WWW-Authenticate header. Requests without any
+// credentials get no error code, as RFC 6750 section 3.1 asks.
+func challenge(c *gin.Context, status int, code, description string) {
+	header := `Bearer realm="api"`
+	if code != "" {
+		header += fmt.Sprintf(`, error=%q, error_description=%q`, code, sanitize(description))
+	}
+	c.Header("WWW-Authenticate", header)
+
+	body := gin.H{"error": code, "error_description": description}
+	if code == "" {
+		body = gin.H{"error": "No authorization header"}
+	}
+	c.AbortWithStatusJSON(status, body)
+}
+
+// sanitize keeps descriptions within the characters RFC 6750 allows.
+func sanitize(description string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return ' '
+		}
+		return r
+	}, description)
 }
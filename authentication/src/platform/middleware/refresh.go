@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"authentication/src/platform/authenticator"
+
+	"golang.org/x/oauth2"
+)
+
+// refreshReuse is how long the result of a successful refresh is handed to
+// requests that still carry the refresh token it used up.
+const refreshReuse = time.Minute
+
+// refreshes makes sure each refresh token is only sent to the provider once.
+// Parallel API calls of a session would otherwise all refresh with the same
+// token, and providers that detect reuse of rotated refresh tokens, such as
+// Auth0, then revoke the whole token family and log the user out.
+var refreshes = &refresher{calls: make(map[[sha256.Size]byte]*refreshCall)}
+
+type refresher struct {
+	mu    sync.Mutex
+	calls map[[sha256.Size]byte]*refreshCall
+}
+
+type refreshCall struct {
+	done  chan struct{}
+	token *oauth2.Token
+	err   error
+}
+
+// refresh exchanges refreshToken once. Callers with the same token while the
+// exchange runs get its result, and shortly after only if it succeeded, so
+// a transient failure can be retried at once.
+func (r *refresher) refresh(ctx context.Context, auth *authenticator.Authenticator, refreshToken string) (*oauth2.Token, error) {
+	key := sha256.Sum256([]byte(refreshToken))
+
+	r.mu.Lock()
+	if call, ok := r.calls[key]; ok {
+		r.mu.Unlock()
+		<-call.done
+		return call.token, call.err
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	r.calls[key] = call
+	r.mu.Unlock()
+
+	// The waiting requests must not fail because this one was cancelled
+	call.token, call.err = auth.Refresh(context.WithoutCancel(ctx), refreshToken)
+	close(call.done)
+
+	forget := func() {
+		r.mu.Lock()
+		delete(r.calls, key)
+		r.mu.Unlock()
+	}
+	if call.err != nil {
+		forget()
+	} else {
+		time.AfterFunc(refreshReuse, forget)
+	}
+	return call.token, call.err
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+
+	"authentication/src/platform/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantKey is the gin context key Tenant stores the resolved tenant under.
+const TenantKey = "tenant"
+
+// Tenant is a middleware that resolves the tenant a request is for, from the
+// :tenant path parameter, then the host and finally the default tenant.
+func Tenant(tenants *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var t *tenant.Tenant
+		if id := c.Param("tenant"); id != "" {
+			var err error
+			if t, err = tenants.Get(id); err != nil {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+		} else if byHost, ok := tenants.ForHost(c.Request.Host); ok {
+			t = byHost
+		} else {
+			t, _ = tenants.Get("")
+		}
+
+		c.Set(TenantKey, t)
+		c.Next()
+	}
+}
+
+// GetTenant returns the tenant resolved by the Tenant middleware.
+func GetTenant(c *gin.Context) *tenant.Tenant {
+	return c.MustGet(TenantKey).(*tenant.Tenant)
+}
+
+// TenantPath prefixes p with the tenant path when the request addressed its
+// tenant through the path.
+func TenantPath(c *gin.Context, p string) string {
+	if id := c.Param("tenant"); id != "" {
+		return "/t/" + id + p
+	}
+	return p
+}
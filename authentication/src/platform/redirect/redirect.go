@@ -0,0 +1,167 @@
+package redirect
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+var ErrNotAllowed = errors.New("redirect destination is not allowed")
+
+// rule is a single allow-list entry. An empty origin matches relative,
+// same-origin paths.
+type rule struct {
+	origin string
+	prefix string
+}
+
+// AllowList holds the destinations users may be sent to after login or
+// logout. Entries are either an exact origin ("https://store.example.com"),
+// an origin with a path prefix ("https://store.example.com/account/") or a
+// local path prefix ("/user"). Tenants can have their own entries on top of
+// the default ones.
+type AllowList struct {
+	mu      sync.RWMutex
+	rules   []rule
+	tenants map[string][]rule
+}
+
+// New instantiates an *AllowList from the given entries.
+func New(entries []string) (*AllowList, error) {
+	rules, err := parseRules(entries)
+	if err != nil {
+		return nil, err
+	}
+	return &AllowList{rules: rules, tenants: make(map[string][]rule)}, nil
+}
+
+// FromEnv builds an *AllowList from the comma separated ALLOWED_REDIRECTS
+// variable. Only local paths are allowed when it is not set.
+func FromEnv() (*AllowList, error) {
+	entries := Split(os.Getenv("ALLOWED_REDIRECTS"))
+	if len(entries) == 0 {
+		entries = []string{"/"}
+	}
+	return New(entries)
+}
+
+// SetTenant replaces the extra entries allowed for tenant.
+func (a *AllowList) SetTenant(tenant string, entries []string) error {
+	rules, err := parseRules(entries)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tenants[tenant] = rules
+	return nil
+}
+
+// Check validates raw against the default and tenant entries and returns the
+// normalized destination.
+func (a *AllowList) Check(tenant, raw string) (string, error) {
+	u, err := normalize(raw)
+	if err != nil {
+		return "", err
+	}
+
+	origin := ""
+	if u.IsAbs() {
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, rules := range [][]rule{a.rules, a.tenants[tenant]} {
+		for _, r := range rules {
+			if r.origin == origin && hasPathPrefix(u.Path, r.prefix) {
+				return u.String(), nil
+			}
+		}
+	}
+	return "", ErrNotAllowed
+}
+
+// Split breaks a comma separated list into trimmed, non-empty entries.
+func Split(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func parseRules(entries []string) ([]rule, error) {
+	rules := make([]rule, 0, len(entries))
+	for _, entry := range entries {
+		u, err := normalize(entry)
+		if err != nil {
+			return nil, errors.New("invalid redirect allow-list entry " + entry)
+		}
+
+		r := rule{prefix: u.Path}
+		if u.IsAbs() {
+			r.origin = u.Scheme + "://" + u.Host
+		}
+		if r.prefix == "" {
+			r.prefix = "/"
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// normalize parses raw and rejects anything that browsers could interpret as
+// a different destination than we do.
+func normalize(raw string) (*url.URL, error) {
+	if raw == "" || strings.ContainsAny(raw, "\\\t\r\n") {
+		return nil, ErrNotAllowed
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.User != nil || u.Opaque != "" {
+		return nil, ErrNotAllowed
+	}
+
+	if u.IsAbs() {
+		if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, ErrNotAllowed
+		}
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+	} else if u.Host != "" || !strings.HasPrefix(u.Path, "/") || strings.HasPrefix(raw, "//") {
+		return nil, ErrNotAllowed
+	}
+
+	if u.Path != "" {
+		cleaned := path.Clean(u.Path)
+		if strings.HasSuffix(u.Path, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		u.Path = cleaned
+		u.RawPath = ""
+	}
+	return u, nil
+}
+
+// hasPathPrefix reports whether p is prefix or lies below it, respecting
+// segment boundaries.
+func hasPathPrefix(p, prefix string) bool {
+	if p == "" {
+		p = "/"
+	}
+	if prefix == "/" || p == prefix || p+"/" == prefix {
+		return true
+	}
+	if strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(p, prefix)
+	}
+	return strings.HasPrefix(p, prefix+"/")
+}
@@ -0,0 +1,201 @@
+package revocation
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+var ErrRevoked = errors.New("token has been revoked")
+
+// Store is the denylist of revoked tokens. Entries only have to be kept
+// until the token would have expired anyway. It also keeps not-before
+// epochs: tokens issued up to the epoch of their subject or tenant are
+// revoked as well, see Covers.
+type Store interface {
+	// Revoke denies the token with key until expiresAt.
+	Revoke(ctx context.Context, key string, expiresAt time.Time) error
+	// IsRevoked reports whether the token with key has been revoked.
+	IsRevoked(ctx context.Context, key string) (bool, error)
+	// RevokeBefore denies the subject's tokens issued up to notBefore. An
+	// empty subject covers the whole tenant.
+	RevokeBefore(ctx context.Context, tenantID, subject string, notBefore time.Time) error
+	// NotBefore returns the latest epoch of the subject and its tenant, or
+	// the zero time if there is none.
+	NotBefore(ctx context.Context, tenantID, subject string) (time.Time, error)
+}
+
+// epoch identifies a subject, or a whole tenant when subject is empty.
+type epoch struct {
+	tenantID string
+	subject  string
+}
+
+// Epoch returns the not-before time of a revocation made at t. iat only has
+// second precision, so epochs are truncated to the second as well.
+func Epoch(t time.Time) time.Time {
+	return t.Truncate(time.Second)
+}
+
+// Covers reports whether the epoch notBefore revokes a token issued at
+// issuedAt. Both are compared at second precision and the boundary is
+// inclusive: a token issued in the same second as the revocation is
+// revoked, whether it was issued just before or just after it. Tokens
+// without iat cannot be told apart, so any epoch covers them.
+func Covers(notBefore, issuedAt time.Time) bool {
+	return !notBefore.IsZero() && !issuedAt.Truncate(time.Second).After(notBefore)
+}
+
+// Key identifies a token in the denylist: its jti, or a hash of the raw token
+// for providers that do not set one.
+func Key(jti, rawToken string) string {
+	if jti != "" {
+		return "jti:" + jti
+	}
+	sum := sha256.Sum256([]byte(rawToken))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// FromEnv returns a Postgres backed Store when DATABASE_URL is set, so all
+// replicas share the denylist, and an in-memory one otherwise.
+func FromEnv(ctx context.Context) (Store, error) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		return NewMemory(), nil
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgres(ctx, db)
+}
+
+// Memory is a Store for a single replica.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	epochs  map[epoch]time.Time
+	now     func() time.Time
+}
+
+// NewMemory instantiates an empty *Memory.
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]time.Time), epochs: make(map[epoch]time.Time), now: time.Now}
+}
+
+func (m *Memory) Revoke(ctx context.Context, key string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Forget tokens that expired in the meantime
+	now := m.now()
+	for k, exp := range m.entries {
+		if !exp.After(now) {
+			delete(m.entries, k)
+		}
+	}
+
+	if expiresAt.After(m.entries[key]) {
+		m.entries[key] = expiresAt
+	}
+	return nil
+}
+
+func (m *Memory) IsRevoked(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	exp, ok := m.entries[key]
+	return ok && exp.After(m.now()), nil
+}
+
+func (m *Memory) RevokeBefore(ctx context.Context, tenantID, subject string, notBefore time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := epoch{tenantID: tenantID, subject: subject}
+	if notBefore.After(m.epochs[e]) {
+		m.epochs[e] = notBefore
+	}
+	return nil
+}
+
+func (m *Memory) NotBefore(ctx context.Context, tenantID, subject string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user := m.epochs[epoch{tenantID: tenantID, subject: subject}]
+	tenant := m.epochs[epoch{tenantID: tenantID}]
+	if tenant.After(user) {
+		return tenant, nil
+	}
+	return user, nil
+}
+
+// Postgres is a Store shared by all replicas.
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres instantiates a *Postgres and creates its table if needed.
+func NewPostgres(ctx context.Context, db *sql.DB) (*Postgres, error) {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS revoked_tokens (
+		key        TEXT PRIMARY KEY,
+		expires_at TIMESTAMPTZ NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS revocation_epochs (
+		tenant_id  TEXT NOT NULL,
+		subject    TEXT NOT NULL,
+		not_before TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (tenant_id, subject)
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	return &Postgres{db: db}, nil
+}
+
+func (p *Postgres) Revoke(ctx context.Context, key string, expiresAt time.Time) error {
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at <= now()`); err != nil {
+		return err
+	}
+	_, err := p.db.ExecContext(ctx, `INSERT INTO revoked_tokens (key, expires_at) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET expires_at = GREATEST(revoked_tokens.expires_at, EXCLUDED.expires_at)`,
+		key, expiresAt)
+	return err
+}
+
+func (p *Postgres) IsRevoked(ctx context.Context, key string) (bool, error) {
+	var revoked bool
+	err := p.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE key = $1 AND expires_at > now())`, key,
+	).Scan(&revoked)
+	return revoked, err
+}
+
+func (p *Postgres) RevokeBefore(ctx context.Context, tenantID, subject string, notBefore time.Time) error {
+	_, err := p.db.ExecContext(ctx, `INSERT INTO revocation_epochs (tenant_id, subject, not_before) VALUES ($1, $2, $3)
+		ON CONFLICT (tenant_id, subject) DO UPDATE SET not_before = GREATEST(revocation_epochs.not_before, EXCLUDED.not_before)`,
+		tenantID, subject, notBefore)
+	return err
+}
+
+func (p *Postgres) NotBefore(ctx context.Context, tenantID, subject string) (time.Time, error) {
+	var notBefore sql.NullTime
+	err := p.db.QueryRowContext(ctx,
+		`SELECT max(not_before) FROM revocation_epochs WHERE tenant_id = $1 AND subject IN ($2, '')`,
+		tenantID, subject,
+	).Scan(&notBefore)
+	return notBefore.Time, err
+}
@@ -0,0 +1,36 @@
+package revocation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCovers(t *testing.T) {
+	revokedAt := time.Date(2024, 1, 1, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+	notBefore := Epoch(revokedAt)
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		want     bool
+	}{
+		{"earlier second", notBefore.Add(-time.Second), true},
+		{"same second, before the revocation", revokedAt.Add(-100 * time.Millisecond), true},
+		{"same second, after the revocation", revokedAt.Add(100 * time.Millisecond), true},
+		{"iat of the same second", notBefore, true},
+		{"next second", notBefore.Add(time.Second), false},
+		{"no iat", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Covers(notBefore, tt.issuedAt); got != tt.want {
+				t.Errorf("Covers(%v, %v) = %v, want %v", notBefore, tt.issuedAt, got, tt.want)
+			}
+		})
+	}
+
+	if Covers(time.Time{}, notBefore) {
+		t.Error("the zero epoch revokes tokens")
+	}
+}
@@ -1,28 +1,80 @@
 package router
 
 import (
-	"authentication/src/platform/authenticator"
+	"authentication/src/platform/apikey"
+	"authentication/src/platform/authz"
+	"authentication/src/platform/bff"
+	"authentication/src/platform/clientauth"
+	"authentication/src/platform/directory"
+	"authentication/src/platform/middleware"
+	"authentication/src/platform/redirect"
+	"authentication/src/platform/revocation"
+	"authentication/src/platform/session"
+	"authentication/src/platform/state"
+	"authentication/src/platform/tenant"
+	"authentication/src/web/app/backchannel"
 	"authentication/src/web/app/callback"
 	"authentication/src/web/app/home"
+	"authentication/src/web/app/introspect"
 	"authentication/src/web/app/login"
 	"authentication/src/web/app/logout"
+	"authentication/src/web/app/me"
+	"authentication/src/web/app/proxy"
+	"authentication/src/web/app/refresh"
+	"authentication/src/web/app/revoke"
+	"authentication/src/web/app/tokens"
 	"authentication/src/web/app/user"
 
 	"github.com/gin-gonic/gin"
 )
 
-func New(auth *authenticator.Authenticator) *gin.Engine {
+func New(tenants *tenant.Registry, states *state.Store, cookie *state.Cookie, redirects *redirect.AllowList, clients *clientauth.Clients, sessions *session.Manager, revocations revocation.Store, web bff.Config, keys *apikey.Keys, policy *authz.Policy, users *directory.Directory) *gin.Engine {
 	router := gin.Default()
 
 	router.Static("/public", "web/static")
 	router.LoadHTMLGlob("web/template/*")
 
-	// Public routes
-	router.GET("/", home.Handler)
-	router.GET("/login", login.Handler(auth))
-	router.GET("/callback", callback.Handler(auth))
-	router.GET("/user", user.Handler) // Move this out of API group
-	router.GET("/logout", logout.Handler)
+	// Tenants are resolved from the host, or from the path under /t/:tenant
+	resolve := middleware.Tenant(tenants)
+	routes(router.Group("/", resolve), tenants, states, cookie, redirects, clients, sessions, revocations, web, keys, policy, users)
+	routes(router.Group("/t/:tenant", resolve), tenants, states, cookie, redirects, clients, sessions, revocations, web, keys, policy, users)
 
 	return router
 }
+
+func routes(group *gin.RouterGroup, tenants *tenant.Registry, states *state.Store, cookie *state.Cookie, redirects *redirect.AllowList, clients *clientauth.Clients, sessions *session.Manager, revocations revocation.Store, web bff.Config, keys *apikey.Keys, policy *authz.Policy, users *directory.Directory) {
+	// Public routes
+	group.GET("/", home.Handler)
+	group.GET("/login", login.Handler(states, cookie, redirects))
+	group.GET("/callback", callback.Handler(states, cookie, sessions, users, web.Legacy()))
+	logoutCookie := cookie.Named(state.LogoutCookieName)
+	group.POST("/logout", logout.Handler(tenants, states, logoutCookie, redirects, sessions))
+	group.GET("/logout/callback", logout.Callback(states, logoutCookie))
+	group.POST("/token/refresh", refresh.Handler)
+
+	// OAuth endpoints for confidential clients such as API gateways
+	group.POST("/oauth/introspect", introspect.Handler(tenants, clients))
+	group.POST("/oauth/revoke", revoke.Handler(tenants, clients))
+
+	// Called by the identity provider when a user logs out there
+	group.POST("/backchannel-logout", backchannel.Handler(tenants, sessions, revocations))
+
+	// Web pages, authenticated with the session cookie
+	pages := group.Group("/", middleware.IsAuthenticated(sessions))
+	pages.GET("/user", user.Handler(users))
+	pages.GET("/tokens", tokens.Page)
+
+	// API routes, authenticated with a bearer token, or in BFF mode with the
+	// session cookie
+	apiSessions := sessions
+	if web.Legacy() {
+		apiSessions = nil
+	}
+	api := group.Group("/api", middleware.AuthRequired(tenants, apiSessions))
+	api.GET("/me", me.Handler)
+	api.PUT("/me/privacy", me.Privacy(users))
+	api.Any("/proxy/:service/*path", proxy.Handler(web.Upstreams))
+	api.GET("/tokens", tokens.List(keys, policy))
+	api.POST("/tokens", tokens.Create(keys, policy))
+	api.DELETE("/tokens/:id", tokens.Revoke(keys))
+}
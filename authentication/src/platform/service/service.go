@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"authentication/src/platform/tenant"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// expiryMargin is how long before expiry cached tokens are replaced.
+const expiryMargin = time.Minute
+
+var (
+	ErrUnauthorized = errors.New("service authentication failed")
+	ErrNotAllowed   = errors.New("service is not allowed this audience or scope")
+)
+
+// Service is an internal service, e.g. a moderation worker, that may get
+// tokens with the client credentials grant. Its client is registered at the
+// tenant's identity provider with the same credentials.
+type Service struct {
+	ClientID string `json:"client_id"`
+	// ClientSecretEnv names the variable holding the client secret.
+	ClientSecretEnv string `json:"client_secret_env"`
+	ClientSecret    string `json:"client_secret"`
+	// Tenants the service may get tokens for. Empty means every tenant.
+	Tenants []string `json:"tenants"`
+	// Audiences the service may ask for. The first one is the default.
+	Audiences []string `json:"audiences"`
+	// Scopes the service may ask for.
+	Scopes []string `json:"scopes"`
+}
+
+// Request asks for a service token.
+type Request struct {
+	ClientID     string
+	ClientSecret string
+	Audience     string
+	Scopes       []string
+}
+
+type cacheKey struct {
+	tenantID string
+	clientID string
+	audience string
+	scopes   string
+}
+
+// cached is a token shared by all callers with the same cacheKey. mu is
+// held while fetching, so concurrent callers share one request.
+type cached struct {
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// Registry holds the internal services and caches their tokens.
+type Registry struct {
+	services map[string]*Service
+	secrets  map[string][sha256.Size]byte
+	now      func() time.Time
+
+	mu     sync.Mutex
+	tokens map[cacheKey]*cached
+}
+
+// New instantiates a *Registry for services.
+func New(services ...*Service) (*Registry, error) {
+	r := &Registry{
+		services: make(map[string]*Service, len(services)),
+		secrets:  make(map[string][sha256.Size]byte, len(services)),
+		now:      time.Now,
+		tokens:   make(map[cacheKey]*cached),
+	}
+	for _, s := range services {
+		if s.ClientSecretEnv != "" {
+			s.ClientSecret = os.Getenv(s.ClientSecretEnv)
+		}
+		if s.ClientID == "" || s.ClientSecret == "" {
+			return nil, fmt.Errorf("service %q needs a client id and secret", s.ClientID)
+		}
+		r.services[s.ClientID] = s
+		r.secrets[s.ClientID] = sha256.Sum256([]byte(s.ClientSecret))
+	}
+	return r, nil
+}
+
+// Load reads the services from the JSON file SERVICES_FILE, of the form
+// {"services": [...]}. Without it no service can get tokens.
+func Load() (*Registry, error) {
+	path := os.Getenv("SERVICES_FILE")
+	if path == "" {
+		return New()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Services []*Service `json:"services"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return New(file.Services...)
+}
+
+// Issue returns an access token for req from the tenant's provider. Tokens
+// are cached until shortly before they expire.
+func (r *Registry) Issue(ctx context.Context, t *tenant.Tenant, req Request) (*oauth2.Token, error) {
+	s, err := r.authenticate(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Tenants) > 0 && !slices.Contains(s.Tenants, t.ID) {
+		return nil, fmt.Errorf("%w: tenant %s", ErrNotAllowed, t.ID)
+	}
+
+	audience := req.Audience
+	if audience == "" && len(s.Audiences) > 0 {
+		audience = s.Audiences[0]
+	}
+	if audience != "" && !slices.Contains(s.Audiences, audience) {
+		return nil, fmt.Errorf("%w: audience %s", ErrNotAllowed, audience)
+	}
+	scopes := slices.Clone(req.Scopes)
+	for _, scope := range scopes {
+		if !slices.Contains(s.Scopes, scope) {
+			return nil, fmt.Errorf("%w: scope %s", ErrNotAllowed, scope)
+		}
+	}
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	entry := r.entry(cacheKey{tenantID: t.ID, clientID: s.ClientID, audience: audience, scopes: strings.Join(scopes, " ")})
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.token != nil && entry.token.Expiry.After(r.now().Add(expiryMargin)) {
+		return entry.token, nil
+	}
+
+	config := clientcredentials.Config{
+		ClientID:     s.ClientID,
+		ClientSecret: s.ClientSecret,
+		TokenURL:     t.Auth.OAuth2().Endpoint.TokenURL,
+		Scopes:       scopes,
+	}
+	if audience != "" {
+		config.EndpointParams = map[string][]string{"audience": {audience}}
+	}
+	token, err := config.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry.token = token
+	return token, nil
+}
+
+func (r *Registry) authenticate(clientID, secret string) (*Service, error) {
+	want, known := r.secrets[clientID]
+	got := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(want[:], got[:]) != 1 || !known {
+		return nil, ErrUnauthorized
+	}
+	return r.services[clientID], nil
+}
+
+func (r *Registry) entry(key cacheKey) *cached {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.tokens[key]
+	if !ok {
+		entry = &cached{}
+		r.tokens[key] = entry
+	}
+	return entry
+}
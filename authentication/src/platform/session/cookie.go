@@ -0,0 +1,127 @@
+package session
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// maxCookieSize leaves room for the cookie's name and attributes within the
+// 4096 bytes browsers accept.
+const maxCookieSize = 3800
+
+// CookieStore keeps the whole session in the cookie, compressed and
+// encrypted with AES-GCM. Nothing is stored server side, so Delete cannot
+// invalidate copies of the cookie; the timeouts still apply to them.
+//
+// The profile is left out, it duplicates the ID token claims and is read
+// back from the ID token on Load. Sessions still have to fit in one cookie,
+// so providers with very large tokens need a server side store.
+type CookieStore struct {
+	aead cipher.AEAD
+}
+
+// NewCookieStore instantiates a *CookieStore keyed with secret.
+func NewCookieStore(secret []byte) *CookieStore {
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		// A 32 byte key is always valid
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &CookieStore{aead: aead}
+}
+
+type sealed struct {
+	Session   Session   `json:"s"`
+	ExpiresAt time.Time `json:"e"`
+}
+
+func (c *CookieStore) Save(ctx context.Context, s *Session, expiresAt time.Time) (string, error) {
+	stored := *s
+	stored.Profile = nil
+
+	var plaintext bytes.Buffer
+	w, err := flate.NewWriter(&plaintext, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if err := json.NewEncoder(w).Encode(sealed{Session: stored, ExpiresAt: expiresAt}); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	value := base64.RawURLEncoding.EncodeToString(c.aead.Seal(nonce, nonce, plaintext.Bytes(), []byte(CookieName)))
+	if len(value) > maxCookieSize {
+		return "", ErrTooLarge
+	}
+	return value, nil
+}
+
+func (c *CookieStore) Load(ctx context.Context, value string) (*Session, error) {
+	ciphertext, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(ciphertext) < c.aead.NonceSize() {
+		return nil, ErrMissing
+	}
+	nonce, ciphertext := ciphertext[:c.aead.NonceSize()], ciphertext[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(CookieName))
+	if err != nil {
+		return nil, ErrMissing
+	}
+
+	var s sealed
+	if err := json.NewDecoder(flate.NewReader(bytes.NewReader(plaintext))).Decode(&s); err != nil {
+		return nil, ErrMissing
+	}
+	if !s.ExpiresAt.After(time.Now()) {
+		return nil, ErrMissing
+	}
+	s.Session.Profile = idTokenClaims(s.Session.IDToken)
+	return &s.Session, nil
+}
+
+// idTokenClaims decodes the claims of an ID token that was verified at
+// login. The cookie is authenticated, so the token is not verified again.
+func idTokenClaims(idToken string) map[string]interface{} {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil
+	}
+	return claims
+}
+
+func (c *CookieStore) Delete(ctx context.Context, value string) error {
+	return nil
+}
+
+// DeleteMatching cannot find cookie sessions, so it deletes nothing. Revoke
+// the user's tokens to lock such sessions out of APIs.
+func (c *CookieStore) DeleteMatching(ctx context.Context, tenantID, subject, sid string) ([]*Session, error) {
+	return nil, nil
+}
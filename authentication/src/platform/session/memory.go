@@ -0,0 +1,86 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	session   Session
+	expiresAt time.Time
+}
+
+// Memory is a Store for a single replica.
+type Memory struct {
+	mu       sync.Mutex
+	sessions map[string]entry
+	now      func() time.Time
+}
+
+// NewMemory instantiates an empty *Memory.
+func NewMemory() *Memory {
+	return &Memory{sessions: make(map[string]entry), now: time.Now}
+}
+
+func (m *Memory) Save(ctx context.Context, s *Session, expiresAt time.Time) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Forget sessions that expired in the meantime
+	now := m.now()
+	for id, e := range m.sessions {
+		if !e.expiresAt.After(now) {
+			delete(m.sessions, id)
+		}
+	}
+
+	m.sessions[s.ID] = entry{session: *s, expiresAt: expiresAt}
+	return s.ID, nil
+}
+
+func (m *Memory) Load(ctx context.Context, value string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.sessions[value]
+	if !ok || !e.expiresAt.After(m.now()) {
+		return nil, ErrMissing
+	}
+	s := e.session
+	return &s, nil
+}
+
+func (m *Memory) DeleteMatching(ctx context.Context, tenantID, subject, sid string) ([]*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted []*Session
+	for id, e := range m.sessions {
+		if matches(&e.session, tenantID, subject, sid) {
+			s := e.session
+			deleted = append(deleted, &s)
+			delete(m.sessions, id)
+		}
+	}
+	return deleted, nil
+}
+
+// matches reports whether s is covered by DeleteMatching's arguments.
+func matches(s *Session, tenantID, subject, sid string) bool {
+	if s.TenantID != tenantID {
+		return false
+	}
+	if sid != "" {
+		return s.SID == sid && (subject == "" || s.Subject == subject)
+	}
+	return subject != "" && s.Subject == subject
+}
+
+func (m *Memory) Delete(ctx context.Context, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, value)
+	return nil
+}
@@ -0,0 +1,105 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// Postgres is a Store shared by all replicas.
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres instantiates a *Postgres and creates its table if needed.
+func NewPostgres(ctx context.Context, db *sql.DB) (*Postgres, error) {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS sessions (
+		id         TEXT PRIMARY KEY,
+		tenant_id  TEXT NOT NULL,
+		subject    TEXT NOT NULL,
+		data       JSONB NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	return &Postgres{db: db}, nil
+}
+
+func (p *Postgres) Save(ctx context.Context, s *Session, expiresAt time.Time) (string, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= now()`); err != nil {
+		return "", err
+	}
+	_, err = p.db.ExecContext(ctx, `INSERT INTO sessions (id, tenant_id, subject, data, expires_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, expires_at = EXCLUDED.expires_at`,
+		s.ID, s.TenantID, s.Subject, data, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	return s.ID, nil
+}
+
+func (p *Postgres) Load(ctx context.Context, value string) (*Session, error) {
+	var data []byte
+	err := p.db.QueryRowContext(ctx,
+		`SELECT data FROM sessions WHERE id = $1 AND expires_at > now()`, value,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMissing
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (p *Postgres) DeleteMatching(ctx context.Context, tenantID, subject, sid string) ([]*Session, error) {
+	var rows *sql.Rows
+	var err error
+	if sid != "" {
+		rows, err = p.db.QueryContext(ctx, `DELETE FROM sessions
+			WHERE tenant_id = $1 AND data->>'sid' = $2 AND ($3 = '' OR subject = $3)
+			RETURNING data`, tenantID, sid, subject)
+	} else {
+		rows, err = p.db.QueryContext(ctx, `DELETE FROM sessions
+			WHERE tenant_id = $1 AND subject = $2 AND $2 <> ''
+			RETURNING data`, tenantID, subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deleted []*Session
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var s Session
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		deleted = append(deleted, &s)
+	}
+	return deleted, rows.Err()
+}
+
+func (p *Postgres) Delete(ctx context.Context, value string) error {
+	_, err := p.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1`, value)
+	return err
+}
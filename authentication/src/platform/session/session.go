@@ -0,0 +1,260 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CookieName is the cookie carrying the session reference.
+const CookieName = "session"
+
+const (
+	DefaultIdleTimeout     = 30 * time.Minute
+	DefaultAbsoluteTimeout = 12 * time.Hour
+
+	// touchInterval limits how often LastSeen is written back
+	touchInterval = time.Minute
+)
+
+var (
+	ErrMissing  = errors.New("no session")
+	ErrExpired  = errors.New("session has expired")
+	ErrTooLarge = errors.New("session does not fit into a cookie")
+)
+
+// Session is a logged in browser.
+type Session struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+	Subject  string `json:"sub"`
+	// SID is the provider's session ID, used by back-channel logout.
+	SID string `json:"sid,omitempty"`
+	// Profile holds the ID token claims.
+	Profile      map[string]interface{} `json:"profile"`
+	IDToken      string                 `json:"id_token,omitempty"`
+	AccessToken  string                 `json:"access_token,omitempty"`
+	RefreshToken string                 `json:"refresh_token,omitempty"`
+	TokenExpiry  time.Time              `json:"token_expiry,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+	LastSeen     time.Time              `json:"last_seen"`
+}
+
+// Store keeps sessions. The value it returns from Save is what the session
+// cookie carries.
+type Store interface {
+	// Save creates or updates s, which may be dropped after expiresAt.
+	Save(ctx context.Context, s *Session, expiresAt time.Time) (string, error)
+	// Load returns the session for a cookie value, or ErrMissing.
+	Load(ctx context.Context, value string) (*Session, error)
+	// Delete removes the session for a cookie value.
+	Delete(ctx context.Context, value string) error
+	// DeleteMatching removes the tenant's sessions with the provider session
+	// sid, or all sessions of subject when sid is empty, and returns them.
+	DeleteMatching(ctx context.Context, tenantID, subject, sid string) ([]*Session, error)
+}
+
+// Options tune a *Manager. Zero values use the defaults.
+type Options struct {
+	// IdleTimeout ends sessions that were not used for this long.
+	IdleTimeout time.Duration
+	// AbsoluteTimeout ends sessions this long after login, used or not.
+	AbsoluteTimeout time.Duration
+	// Insecure lets the cookie be sent over plain HTTP, for development
+	// without TLS. Otherwise it is Secure, also when TLS ends at a proxy.
+	Insecure bool
+}
+
+// Manager ties sessions in a Store to browsers through the session cookie.
+type Manager struct {
+	store Store
+	opts  Options
+	now   func() time.Time
+}
+
+// NewManager instantiates a *Manager for store.
+func NewManager(store Store, opts Options) *Manager {
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	if opts.AbsoluteTimeout <= 0 {
+		opts.AbsoluteTimeout = DefaultAbsoluteTimeout
+	}
+	return &Manager{store: store, opts: opts, now: time.Now}
+}
+
+// FromEnv builds a *Manager with the store picked by SESSION_STORE: "memory"
+// (the default), "cookie" (encrypted with SESSION_SECRET) or "postgres"
+// (using DATABASE_URL). SESSION_IDLE_TIMEOUT and SESSION_ABSOLUTE_TIMEOUT
+// set the timeouts, SESSION_COOKIE_SECURE=false allows plain HTTP cookies.
+func FromEnv(ctx context.Context) (*Manager, error) {
+	var opts Options
+	if value := os.Getenv("SESSION_COOKIE_SECURE"); value != "" {
+		secure, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SESSION_COOKIE_SECURE: %w", err)
+		}
+		opts.Insecure = !secure
+	}
+	for name, target := range map[string]*time.Duration{
+		"SESSION_IDLE_TIMEOUT":     &opts.IdleTimeout,
+		"SESSION_ABSOLUTE_TIMEOUT": &opts.AbsoluteTimeout,
+	} {
+		if value := os.Getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = d
+		}
+	}
+
+	var store Store
+	switch kind := strings.ToLower(os.Getenv("SESSION_STORE")); kind {
+	case "", "memory":
+		store = NewMemory()
+	case "cookie":
+		secret := []byte(os.Getenv("SESSION_SECRET"))
+		if len(secret) == 0 {
+			log.Print("SESSION_SECRET is not set, using a random key for session cookies")
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, err
+			}
+		}
+		store = NewCookieStore(secret)
+	case "postgres":
+		db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+		if err != nil {
+			return nil, err
+		}
+		if store, err = NewPostgres(ctx, db); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown session store %q", kind)
+	}
+	return NewManager(store, opts), nil
+}
+
+// Start logs the browser in with s. Any session the browser already had is
+// dropped and s gets a fresh ID, so an ID planted before login is useless.
+func (m *Manager) Start(w http.ResponseWriter, r *http.Request, s *Session) error {
+	if cookie, err := r.Cookie(CookieName); err == nil {
+		if err := m.store.Delete(r.Context(), cookie.Value); err != nil {
+			return err
+		}
+	}
+
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	now := m.now()
+	s.ID = id
+	s.CreatedAt = now
+	s.LastSeen = now
+	return m.save(w, r, s)
+}
+
+// Get returns the browser's session, enforcing the idle and absolute
+// timeouts.
+func (m *Manager) Get(w http.ResponseWriter, r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return nil, ErrMissing
+	}
+	s, err := m.store.Load(r.Context(), cookie.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	if now.Sub(s.LastSeen) > m.opts.IdleTimeout || now.Sub(s.CreatedAt) > m.opts.AbsoluteTimeout {
+		if err := m.Destroy(w, r); err != nil {
+			return nil, err
+		}
+		return nil, ErrExpired
+	}
+
+	if now.Sub(s.LastSeen) > touchInterval {
+		s.LastSeen = now
+		if err := m.save(w, r, s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Update writes changes to s, e.g. refreshed tokens.
+func (m *Manager) Update(w http.ResponseWriter, r *http.Request, s *Session) error {
+	return m.save(w, r, s)
+}
+
+// Destroy ends the browser's session.
+func (m *Manager) Destroy(w http.ResponseWriter, r *http.Request) error {
+	m.clear(w, r)
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return nil
+	}
+	return m.store.Delete(r.Context(), cookie.Value)
+}
+
+// Terminate ends sessions on the server side, e.g. for back-channel
+// logout. See Store.DeleteMatching.
+func (m *Manager) Terminate(ctx context.Context, tenantID, subject, sid string) ([]*Session, error) {
+	return m.store.DeleteMatching(ctx, tenantID, subject, sid)
+}
+
+func (m *Manager) save(w http.ResponseWriter, r *http.Request, s *Session) error {
+	expiresAt := s.CreatedAt.Add(m.opts.AbsoluteTimeout)
+	if idle := s.LastSeen.Add(m.opts.IdleTimeout); idle.Before(expiresAt) {
+		expiresAt = idle
+	}
+
+	value, err := m.store.Save(r.Context(), s, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Path:     "/",
+		Expires:  s.CreatedAt.Add(m.opts.AbsoluteTimeout),
+		HttpOnly: true,
+		Secure:   !m.opts.Insecure || r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+func (m *Manager) clear(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   !m.opts.Insecure || r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
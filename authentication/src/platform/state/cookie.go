@@ -0,0 +1,132 @@
+package state
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The cookies that bind a browser to the state it was sent off with. Login
+// and logout use their own, so one does not end the other in another tab.
+const (
+	LoginCookieName  = "auth_state"
+	LogoutCookieName = "logout_state"
+)
+
+// Cookie signs and verifies the state cookie of one HTTP flow.
+type Cookie struct {
+	name   string
+	key    []byte
+	ttl    time.Duration
+	secure bool
+}
+
+// NewCookie instantiates the login *Cookie keyed with COOKIE_SECRET. Without
+// a secret a random key is used, which invalidates pending logins on restart.
+// Like the session cookie it is Secure unless SESSION_COOKIE_SECURE is false.
+func NewCookie(ttl time.Duration) (*Cookie, error) {
+	secure := true
+	if value := os.Getenv("SESSION_COOKIE_SECURE"); value != "" {
+		var err error
+		if secure, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("invalid SESSION_COOKIE_SECURE: %w", err)
+		}
+	}
+
+	key := []byte(os.Getenv("COOKIE_SECRET"))
+	if len(key) == 0 {
+		log.Print("COOKIE_SECRET is not set, using a random key for state cookies")
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Cookie{name: LoginCookieName, key: key, ttl: ttl, secure: secure}, nil
+}
+
+// Named returns a copy of c for the cookie called name, e.g. LogoutCookieName.
+func (c *Cookie) Named(name string) *Cookie {
+	named := *c
+	named.name = name
+	return &named
+}
+
+// Set writes a signed cookie carrying state to the response.
+func (c *Cookie) Set(w http.ResponseWriter, r *http.Request, state string) {
+	expires := time.Now().Add(c.ttl)
+	payload := state + "." + strconv.FormatInt(expires.Unix(), 10)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.name,
+		Value:    payload + "." + c.sign(payload),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   c.secure || r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Verify checks that the request carries a valid cookie for state and clears it.
+func (c *Cookie) Verify(w http.ResponseWriter, r *http.Request, state string) error {
+	cookie, err := r.Cookie(c.name)
+	if err != nil {
+		return ErrMissing
+	}
+	c.clear(w, r)
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 {
+		return ErrMismatch
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(c.sign(payload))) {
+		return ErrMismatch
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return ErrMismatch
+	}
+	if time.Now().Unix() >= expires {
+		return ErrExpired
+	}
+
+	if state == "" {
+		return ErrMissing
+	}
+	if !hmac.Equal([]byte(parts[0]), []byte(state)) {
+		return ErrMismatch
+	}
+	return nil
+}
+
+func (c *Cookie) clear(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.name,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.secure || r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sign covers the cookie's name too, so one flow's cookie is no good for another.
+func (c *Cookie) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(c.name + "." + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,138 @@
+package state
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long a login attempt may take before its state expires.
+const DefaultTTL = 10 * time.Minute
+
+var (
+	ErrMissing  = errors.New("state is missing or unknown")
+	ErrExpired  = errors.New("state has expired")
+	ErrUsed     = errors.New("state has already been used")
+	ErrMismatch = errors.New("state does not match")
+)
+
+// Flow is what the service remembers about a pending authorization request.
+type Flow struct {
+	// TenantID is the tenant the login was started for.
+	TenantID string
+	// AuthURL is the provider URL handed out for this state.
+	AuthURL string
+	// CodeVerifier is the PKCE verifier generated by the service.
+	CodeVerifier string
+	// CodeChallenge is a PKCE challenge supplied by the client, which then
+	// has to present the matching verifier itself.
+	CodeChallenge string
+	// RedirectURL is the allow-listed destination to return to after login.
+	RedirectURL string
+
+	expiresAt time.Time
+	used      bool
+}
+
+// Store keeps pending authorization states on the server until they are
+// consumed or expire. Each state can be consumed exactly once.
+type Store struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	now   func() time.Time
+	flows map[string]*Flow
+}
+
+// NewStore instantiates a *Store whose states expire after ttl.
+func NewStore(ttl time.Duration) *Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Store{
+		ttl:   ttl,
+		now:   time.Now,
+		flows: make(map[string]*Flow),
+	}
+}
+
+// Generate returns a new random, URL safe state value.
+func Generate() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// TTL returns how long saved states stay valid.
+func (s *Store) TTL() time.Duration {
+	return s.ttl
+}
+
+// Save remembers flow under state until the store's TTL elapses.
+func (s *Store) Save(state string, flow Flow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flow.expiresAt = s.now().Add(s.ttl)
+	flow.used = false
+	s.flows[state] = &flow
+}
+
+// Consume returns the flow saved under state and marks it as used. Consumed
+// states are kept until they expire so that a replay reports ErrUsed.
+func (s *Store) Consume(state string) (Flow, error) {
+	if state == "" {
+		return Flow{}, ErrMissing
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flow, ok := s.flows[state]
+	if !ok {
+		return Flow{}, ErrMissing
+	}
+	if !s.now().Before(flow.expiresAt) {
+		delete(s.flows, state)
+		return Flow{}, ErrExpired
+	}
+	if flow.used {
+		return Flow{}, ErrUsed
+	}
+
+	flow.used = true
+	return *flow, nil
+}
+
+// Start drops expired states every TTL until ctx is done.
+func (s *Store) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.ttl)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sweep()
+			}
+		}
+	}()
+}
+
+// sweep drops expired entries.
+func (s *Store) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for state, flow := range s.flows {
+		if !now.Before(flow.expiresAt) {
+			delete(s.flows, state)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package state
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// clock is a manually advanced time source.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newStore(ttl time.Duration) (*Store, *clock) {
+	s := NewStore(ttl)
+	clk := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s.now = clk.Now
+	return s, clk
+}
+
+func TestGenerate(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 100 {
+		st, err := Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 32 random bytes in unpadded base64url
+		if len(st) != 43 {
+			t.Errorf("len(%q) = %d, want 43", st, len(st))
+		}
+		if seen[st] {
+			t.Fatalf("state %q was generated twice", st)
+		}
+		seen[st] = true
+	}
+}
+
+func TestConsume(t *testing.T) {
+	tests := []struct {
+		name    string
+		save    string
+		consume string
+		after   time.Duration
+		want    error
+	}{
+		{"valid", "state", "state", time.Minute, nil},
+		{"empty", "state", "", 0, ErrMissing},
+		{"unknown", "state", "other", 0, ErrMissing},
+		{"just before expiry", "state", "state", 10*time.Minute - time.Second, nil},
+		{"at expiry", "state", "state", 10 * time.Minute, ErrExpired},
+		{"expired", "state", "state", time.Hour, ErrExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, clk := newStore(10 * time.Minute)
+			s.Save(tt.save, Flow{TenantID: "shop", RedirectURL: "/account"})
+			clk.Advance(tt.after)
+
+			flow, err := s.Consume(tt.consume)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if err == nil && (flow.TenantID != "shop" || flow.RedirectURL != "/account") {
+				t.Errorf("flow = %+v", flow)
+			}
+		})
+	}
+}
+
+func TestConsumeOnce(t *testing.T) {
+	s, _ := newStore(time.Minute)
+	s.Save("state", Flow{TenantID: "shop"})
+
+	if _, err := s.Consume("state"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Consume("state"); !errors.Is(err, ErrUsed) {
+		t.Errorf("replay err = %v, want ErrUsed", err)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	s, clk := newStore(time.Minute)
+	s.Save("old", Flow{})
+	clk.Advance(30 * time.Second)
+	s.Save("new", Flow{})
+	clk.Advance(30 * time.Second)
+
+	s.sweep()
+
+	if _, ok := s.flows["old"]; ok {
+		t.Error("expired state was kept")
+	}
+	if _, ok := s.flows["new"]; !ok {
+		t.Error("pending state was dropped")
+	}
+}
+
+func newCookie(t *testing.T) *Cookie {
+	t.Helper()
+	t.Setenv("COOKIE_SECRET", "secret")
+	t.Setenv("SESSION_COOKIE_SECURE", "")
+	c, err := NewCookie(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// roundTrip sets st with from and returns a request carrying the cookie.
+func roundTrip(from *Cookie, st string) (*http.Request, *http.Cookie) {
+	rec := httptest.NewRecorder()
+	from.Set(rec, httptest.NewRequest(http.MethodGet, "/login", nil), st)
+	cookie := rec.Result().Cookies()[0]
+
+	req := httptest.NewRequest(http.MethodGet, "/callback", nil)
+	req.AddCookie(cookie)
+	return req, cookie
+}
+
+func TestCookie(t *testing.T) {
+	login := newCookie(t)
+	logout := login.Named(LogoutCookieName)
+
+	tests := []struct {
+		name   string
+		set    *Cookie
+		verify *Cookie
+		state  string
+		want   error
+	}{
+		{"matching state", login, login, "state", nil},
+		{"other state", login, login, "other", ErrMismatch},
+		{"no state", login, login, "", ErrMissing},
+		{"logout flow", logout, logout, "state", nil},
+		{"login cookie for logout", login, logout, "state", ErrMissing},
+		{"logout cookie for login", logout, login, "state", ErrMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := roundTrip(tt.set, "state")
+			if err := tt.verify.Verify(httptest.NewRecorder(), req, tt.state); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCookieRejectsTampering(t *testing.T) {
+	login := newCookie(t)
+	logout := login.Named(LogoutCookieName)
+
+	// A login cookie renamed to the logout cookie does not verify there
+	_, cookie := roundTrip(login, "state")
+	req := httptest.NewRequest(http.MethodGet, "/logout/callback", nil)
+	req.AddCookie(&http.Cookie{Name: LogoutCookieName, Value: cookie.Value})
+	if err := logout.Verify(httptest.NewRecorder(), req, "state"); !errors.Is(err, ErrMismatch) {
+		t.Errorf("renamed cookie err = %v, want ErrMismatch", err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/callback", nil)
+	req.AddCookie(&http.Cookie{Name: LoginCookieName, Value: "other" + cookie.Value[len("state"):]})
+	if err := login.Verify(httptest.NewRecorder(), req, "other"); !errors.Is(err, ErrMismatch) {
+		t.Errorf("edited cookie err = %v, want ErrMismatch", err)
+	}
+}
+
+func TestCookieIsSecure(t *testing.T) {
+	_, cookie := roundTrip(newCookie(t), "state")
+	if !cookie.Secure || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("cookie = %+v, want Secure, HttpOnly and SameSite=Lax", cookie)
+	}
+
+	t.Setenv("SESSION_COOKIE_SECURE", "false")
+	c, err := NewCookie(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, cookie := roundTrip(c, "state"); cookie.Secure {
+		t.Error("cookie is Secure although SESSION_COOKIE_SECURE is false")
+	}
+}
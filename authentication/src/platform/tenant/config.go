@@ -0,0 +1,139 @@
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"authentication/src/platform/authenticator"
+	"authentication/src/platform/identity"
+	"authentication/src/platform/management"
+)
+
+// DefaultID is the tenant used when TENANTS_FILE is not set.
+const DefaultID = "default"
+
+// fileConfig is the layout of TENANTS_FILE.
+type fileConfig struct {
+	// Default is the tenant used when a request does not name one.
+	Default string         `json:"default"`
+	Tenants []tenantConfig `json:"tenants"`
+}
+
+type tenantConfig struct {
+	ID string `json:"id"`
+	// Hosts are the HTTP hosts that belong to this tenant.
+	Hosts []string `json:"hosts"`
+	// Redirects are extra redirect allow-list entries for this tenant.
+	Redirects []string `json:"redirects"`
+
+	Provider  string `json:"provider"`
+	Domain    string `json:"domain"`
+	IssuerURL string `json:"issuer_url"`
+	ClientID  string `json:"client_id"`
+	// ClientSecretEnv names the variable holding the client secret, so
+	// secrets do not have to live in the file.
+	ClientSecretEnv string   `json:"client_secret_env"`
+	ClientSecret    string   `json:"client_secret"`
+	CallbackURL     string   `json:"callback_url"`
+	Scopes          []string `json:"scopes"`
+	OfflineAccess   bool     `json:"offline_access"`
+	Issuer          string   `json:"issuer"`
+	Audiences       []string `json:"audiences"`
+	SigningAlgs     []string `json:"signing_algs"`
+	// Claims overrides the claim mapping for this tenant's tokens.
+	Claims identity.Mapping `json:"claims"`
+	// Management is the client used with the Auth0 Management API.
+	Management managementConfig `json:"management"`
+}
+
+type managementConfig struct {
+	ClientID        string `json:"client_id"`
+	ClientSecretEnv string `json:"client_secret_env"`
+	ClientSecret    string `json:"client_secret"`
+}
+
+// managementFromEnv reads the Management API client of the default tenant.
+func managementFromEnv() managementConfig {
+	return managementConfig{
+		ClientID:     os.Getenv("AUTH0_MANAGEMENT_CLIENT_ID"),
+		ClientSecret: os.Getenv("AUTH0_MANAGEMENT_CLIENT_SECRET"),
+	}
+}
+
+// users returns the management client for a tenant using cfg, or nil when
+// the provider has no management API we support or no client is set up.
+func (m managementConfig) users(cfg authenticator.Config) management.Users {
+	secret := m.ClientSecret
+	if m.ClientSecretEnv != "" {
+		secret = os.Getenv(m.ClientSecretEnv)
+	}
+	if cfg.Provider != authenticator.ProviderAuth0 || m.ClientID == "" || secret == "" {
+		return nil
+	}
+	return management.NewAuth0(cfg.Domain, m.ClientID, secret)
+}
+
+// readFile parses a TENANTS_FILE. Settings a tenant leaves out are taken from
+// base, the configuration read from the environment.
+func readFile(path string, base authenticator.Config) (string, []tenantConfig, map[string]authenticator.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	var file fileConfig
+	if err := json.Unmarshal(data, &file); err != nil {
+		return "", nil, nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(file.Tenants) == 0 {
+		return "", nil, nil, fmt.Errorf("%s defines no tenants", path)
+	}
+	if file.Default == "" {
+		file.Default = file.Tenants[0].ID
+	}
+
+	configs := make(map[string]authenticator.Config, len(file.Tenants))
+	for _, t := range file.Tenants {
+		if t.ID == "" {
+			return "", nil, nil, fmt.Errorf("%s has a tenant without an id", path)
+		}
+		if _, ok := configs[t.ID]; ok {
+			return "", nil, nil, fmt.Errorf("%s defines tenant %q twice", path, t.ID)
+		}
+
+		cfg := base
+		cfg.Provider = strings.ToLower(or(t.Provider, base.Provider))
+		cfg.Domain = t.Domain
+		cfg.IssuerURL = t.IssuerURL
+		cfg.ClientID = t.ClientID
+		cfg.ClientSecret = t.ClientSecret
+		if t.ClientSecretEnv != "" {
+			cfg.ClientSecret = os.Getenv(t.ClientSecretEnv)
+		}
+		cfg.RedirectURL = t.CallbackURL
+		cfg.Scopes = orList(t.Scopes, base.Scopes)
+		cfg.OfflineAccess = t.OfflineAccess || base.OfflineAccess
+		cfg.Issuer = t.Issuer
+		cfg.Audiences = orList(t.Audiences, base.Audiences)
+		cfg.SigningAlgs = orList(t.SigningAlgs, base.SigningAlgs)
+		configs[t.ID] = cfg
+	}
+
+	return file.Default, file.Tenants, configs, nil
+}
+
+func or(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func orList(values, fallback []string) []string {
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
+}
@@ -0,0 +1,399 @@
+package tenant
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"authentication/src/platform/apikey"
+	"authentication/src/platform/authenticator"
+	"authentication/src/platform/identity"
+	"authentication/src/platform/management"
+	"authentication/src/platform/redirect"
+	"authentication/src/platform/revocation"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+var (
+	ErrUnknownTenant = errors.New("unknown tenant")
+	ErrUnknownIssuer = errors.New("token issuer does not belong to any tenant")
+	ErrWrongTenant   = errors.New("token was not issued for this tenant")
+)
+
+// Tenant is a storefront with its own identity provider and client.
+type Tenant struct {
+	ID    string
+	Hosts []string
+	Auth  *authenticator.Authenticator
+	// Claims maps this tenant's custom claims onto principals.
+	Claims identity.Mapping
+	// Users administers accounts at the provider, nil if not configured.
+	Users management.Users
+}
+
+// Registry maps tenants to their identity providers.
+type Registry struct {
+	defaultID string
+	tenants   map[string]*Tenant
+	byHost    map[string]*Tenant
+	byIssuer  map[string][]*Tenant
+
+	// revocations is consulted by Authenticate when set
+	revocations revocation.Store
+	// apiKeys lets Authenticate accept API keys when set
+	apiKeys *apikey.Keys
+}
+
+// Load builds the *Registry. With TENANTS_FILE set every tenant in the file
+// gets its own provider, otherwise a single tenant named DEFAULT_TENANT (or
+// "default") is configured from the environment. Tenant specific redirect
+// entries are added to redirects.
+func Load(ctx context.Context, redirects *redirect.AllowList) (*Registry, error) {
+	base, err := authenticator.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := identity.MappingFromEnv()
+
+	path := os.Getenv("TENANTS_FILE")
+	if path == "" {
+		id := or(os.Getenv("DEFAULT_TENANT"), DefaultID)
+		auth, err := authenticator.NewWithConfig(ctx, base)
+		if err != nil {
+			return nil, err
+		}
+		return New(id, &Tenant{ID: id, Auth: auth, Claims: mapping, Users: managementFromEnv().users(base)})
+	}
+
+	defaultID, tenantConfigs, configs, err := readFile(path, base)
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := make([]*Tenant, 0, len(tenantConfigs))
+	for _, t := range tenantConfigs {
+		auth, err := authenticator.NewWithConfig(ctx, configs[t.ID])
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", t.ID, err)
+		}
+		if err := redirects.SetTenant(t.ID, t.Redirects); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", t.ID, err)
+		}
+		tenants = append(tenants, &Tenant{
+			ID:     t.ID,
+			Hosts:  t.Hosts,
+			Auth:   auth,
+			Claims: mapping.Merge(t.Claims),
+			Users:  t.Management.users(configs[t.ID]),
+		})
+	}
+	return New(defaultID, tenants...)
+}
+
+// New instantiates a *Registry for tenants.
+func New(defaultID string, tenants ...*Tenant) (*Registry, error) {
+	r := &Registry{
+		defaultID: defaultID,
+		tenants:   make(map[string]*Tenant),
+		byHost:    make(map[string]*Tenant),
+		byIssuer:  make(map[string][]*Tenant),
+	}
+
+	for _, t := range tenants {
+		r.tenants[t.ID] = t
+		for _, host := range t.Hosts {
+			host = strings.ToLower(host)
+			if other, ok := r.byHost[host]; ok {
+				return nil, fmt.Errorf("host %s belongs to tenants %s and %s", host, other.ID, t.ID)
+			}
+			r.byHost[host] = t
+		}
+		issuer := t.Auth.Discovery().Issuer
+		r.byIssuer[issuer] = append(r.byIssuer[issuer], t)
+	}
+
+	if _, ok := r.tenants[defaultID]; !ok {
+		return nil, fmt.Errorf("default tenant %q is not configured", defaultID)
+	}
+	return r, nil
+}
+
+// SetRevocations makes Authenticate reject tokens revoked in store.
+func (r *Registry) SetRevocations(store revocation.Store) {
+	r.revocations = store
+}
+
+// SetAPIKeys makes Authenticate accept API keys issued by keys.
+func (r *Registry) SetAPIKeys(keys *apikey.Keys) {
+	r.apiKeys = keys
+}
+
+// Get returns the tenant with id, or the default tenant when id is empty.
+func (r *Registry) Get(id string) (*Tenant, error) {
+	if id == "" {
+		id = r.defaultID
+	}
+	t, ok := r.tenants[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownTenant, id)
+	}
+	return t, nil
+}
+
+// SameIssuer reports whether the tenants with ids a and b share an identity
+// provider, so a sub means the same user in both.
+func (r *Registry) SameIssuer(a, b string) bool {
+	ta, errA := r.Get(a)
+	tb, errB := r.Get(b)
+	return errA == nil && errB == nil && ta.Auth.Discovery().Issuer == tb.Auth.Discovery().Issuer
+}
+
+// ForHost returns the tenant serving host, if any.
+func (r *Registry) ForHost(host string) (*Tenant, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	t, ok := r.byHost[strings.ToLower(host)]
+	return t, ok
+}
+
+// Verify verifies a raw token with the provider of the tenant that issued
+// it. When tenantID is set the token must come from exactly that tenant,
+// otherwise the tenant is picked by the token's iss. Tenants sharing an
+// issuer only accept tokens that are bound to them, see owns.
+func (r *Registry) Verify(ctx context.Context, rawToken, tenantID string) (*oidc.IDToken, authenticator.TokenKind, *Tenant, error) {
+	issuer, err := peekIssuer(rawToken)
+	if err != nil {
+		return nil, authenticator.KindUnknown, nil, err
+	}
+
+	candidates := r.byIssuer[issuer]
+	if tenantID != "" {
+		t, err := r.Get(tenantID)
+		if err != nil {
+			return nil, authenticator.KindUnknown, nil, err
+		}
+		if t.Auth.Discovery().Issuer != issuer {
+			return nil, authenticator.KindUnknown, nil, ErrWrongTenant
+		}
+		candidates = []*Tenant{t}
+	}
+	if len(candidates) == 0 {
+		return nil, authenticator.KindUnknown, nil, ErrUnknownIssuer
+	}
+
+	for _, t := range candidates {
+		token, kind, verr := t.Auth.VerifyToken(ctx, rawToken)
+		if verr != nil {
+			err = verr
+			continue
+		}
+		ok, verr := r.owns(t, token, kind)
+		if verr != nil {
+			return nil, authenticator.KindUnknown, nil, verr
+		}
+		if ok {
+			return token, kind, t, nil
+		}
+		err = ErrWrongTenant
+	}
+	return nil, authenticator.KindUnknown, nil, err
+}
+
+// owns reports whether a token verified by t's provider belongs to t. A
+// token of an issuer no other tenant uses always does. Tenants sharing an
+// issuer may share audiences too, so there the token has to name t in its
+// tenant claim, or be an ID token for a client only t uses.
+func (r *Registry) owns(t *Tenant, token *oidc.IDToken, kind authenticator.TokenKind) (bool, error) {
+	shared := r.byIssuer[t.Auth.Discovery().Issuer]
+	if len(shared) < 2 {
+		return true, nil
+	}
+
+	var claims map[string]interface{}
+	if err := token.Claims(&claims); err != nil {
+		return false, err
+	}
+	if tenantID := t.Claims.TenantOf(claims); tenantID != "" {
+		return tenantID == t.ID, nil
+	}
+	if kind != authenticator.KindIDToken {
+		return false, nil
+	}
+
+	clientID := t.Auth.OAuth2().ClientID
+	for _, other := range shared {
+		if other != t && other.Auth.OAuth2().ClientID == clientID {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Authenticate verifies a raw token like Verify and turns it into a
+// *identity.Principal using the tenant's claim mapping. A tenant claim in the
+// token has to name the tenant that verified it.
+//
+// API keys are accepted as well when SetAPIKeys was called.
+func (r *Registry) Authenticate(ctx context.Context, rawToken, tenantID string) (*identity.Principal, error) {
+	var principal *identity.Principal
+	var err error
+	if apikey.IsKey(rawToken) {
+		principal, err = r.authenticateKey(ctx, rawToken, tenantID)
+	} else {
+		principal, err = r.authenticateJWT(ctx, rawToken, tenantID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if r.revocations != nil {
+		revoked, err := r.revocations.IsRevoked(ctx, revocation.Key(principal.TokenID, rawToken))
+		if err != nil {
+			return nil, fmt.Errorf("checking revocation: %w", err)
+		}
+		if revoked {
+			return nil, revocation.ErrRevoked
+		}
+
+		notBefore, err := r.revocations.NotBefore(ctx, principal.TenantID, principal.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("checking revocation: %w", err)
+		}
+		if revocation.Covers(notBefore, principal.IssuedAt) {
+			return nil, revocation.ErrRevoked
+		}
+	}
+	return principal, nil
+}
+
+func (r *Registry) authenticateJWT(ctx context.Context, rawToken, tenantID string) (*identity.Principal, error) {
+	token, kind, t, err := r.Verify(ctx, rawToken, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	principal, err := identity.FromToken(token, kind, t.Claims)
+	if err != nil {
+		return nil, err
+	}
+	if principal.TenantID != "" && principal.TenantID != t.ID {
+		return nil, ErrWrongTenant
+	}
+	principal.TenantID = t.ID
+	return principal, nil
+}
+
+func (r *Registry) authenticateKey(ctx context.Context, rawKey, tenantID string) (*identity.Principal, error) {
+	if r.apiKeys == nil {
+		return nil, apikey.ErrInvalid
+	}
+	t, err := r.Get(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return r.apiKeys.Verify(ctx, rawKey, t.ID)
+}
+
+// RevokeTokens ends the tokens of a logged out session: the access token is
+// added to the denylist and the refresh token is revoked at the provider,
+// if it supports that. Either token may be empty.
+func (r *Registry) RevokeTokens(ctx context.Context, tenantID, accessToken, refreshToken string) error {
+	t, err := r.Get(tenantID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if accessToken != "" {
+		errs = append(errs, r.Revoke(ctx, accessToken, t.ID))
+	}
+	if refreshToken != "" {
+		if err := t.Auth.RevokeRefreshToken(ctx, refreshToken); !errors.Is(err, authenticator.ErrRevocationUnsupported) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RevokeAll revokes every token of subject in the tenant issued until now,
+// or every token of the tenant when subject is empty. Tokens issued later
+// in the current second are revoked too. It returns the new not-before
+// time.
+func (r *Registry) RevokeAll(ctx context.Context, tenantID, subject string) (time.Time, error) {
+	if r.revocations == nil {
+		return time.Time{}, errors.New("token revocation is not configured")
+	}
+
+	t, err := r.Get(tenantID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	notBefore := revocation.Epoch(time.Now())
+	if err := r.revocations.RevokeBefore(ctx, t.ID, subject, notBefore); err != nil {
+		return time.Time{}, err
+	}
+	return notBefore, nil
+}
+
+// Revoke adds a token to the denylist until it expires, or disables an API
+// key or personal access token for good. Tokens that do not verify are
+// accepted anyway, so there is nothing to do for them.
+func (r *Registry) Revoke(ctx context.Context, rawToken, tenantID string) error {
+	if apikey.IsKey(rawToken) {
+		if r.apiKeys == nil {
+			return nil
+		}
+		t, err := r.Get(tenantID)
+		if err != nil {
+			return err
+		}
+		return r.apiKeys.RevokeRaw(ctx, rawToken, t.ID)
+	}
+	if r.revocations == nil {
+		return errors.New("token revocation is not configured")
+	}
+
+	token, _, _, err := r.Verify(ctx, rawToken, tenantID)
+	if err != nil {
+		return nil
+	}
+
+	var claims struct {
+		ID string `json:"jti"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return err
+	}
+	return r.revocations.Revoke(ctx, revocation.Key(claims.ID, rawToken), token.Expiry)
+}
+
+// peekIssuer reads iss from a JWT without verifying it. It is only used to
+// pick the key set the token is then verified with.
+func peekIssuer(rawToken string) (string, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed jwt")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed jwt payload: %w", err)
+	}
+
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("malformed jwt claims: %w", err)
+	}
+	return claims.Issuer, nil
+}
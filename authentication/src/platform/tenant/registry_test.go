@@ -0,0 +1,199 @@
+package tenant
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"authentication/src/platform/authenticator"
+	"authentication/src/platform/identity"
+
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+// issuer is an OpenID provider signing tokens with a single key.
+type issuer struct {
+	url string
+	key *ecdsa.PrivateKey
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &issuer{key: key}
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	iss.url = server.URL
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 iss.url,
+			"authorization_endpoint": iss.url + "/authorize",
+			"token_endpoint":         iss.url + "/token",
+			"jwks_uri":               iss.url + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "key", Algorithm: string(jose.ES256), Use: "sig"},
+		}})
+	})
+	return iss
+}
+
+// tenant configures a tenant with its own client that accepts access tokens
+// for audiences.
+func (iss *issuer) tenant(t *testing.T, id, clientID string, audiences ...string) *Tenant {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	auth, err := authenticator.NewWithConfig(ctx, authenticator.Config{
+		Provider:    authenticator.ProviderOIDC,
+		IssuerURL:   iss.url,
+		ClientID:    clientID,
+		Audiences:   audiences,
+		SigningAlgs: []string{string(jose.ES256)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Tenant{ID: id, Auth: auth, Claims: identity.DefaultMapping()}
+}
+
+// sign issues a token with claims on top of iss, sub and the timestamps.
+func (iss *issuer) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	payload := map[string]interface{}{
+		"iss": iss.url,
+		"sub": "user-1",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		payload[name] = value
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := (&jose.SignerOptions{}).WithHeader(jose.HeaderKey("kid"), "key")
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: iss.key}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := signer.Sign(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestSharedIssuerKeepsTenantsApart(t *testing.T) {
+	iss := newIssuer(t)
+	// Both tenants accept access tokens for the same API, as when they
+	// inherit the audiences from the environment
+	r, err := New("a", iss.tenant(t, "a", "client-a", "api"), iss.tenant(t, "b", "client-b", "api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		owner  string
+	}{
+		{"access token of a", map[string]interface{}{"aud": "api", "tenant_id": "a"}, "a"},
+		{"access token of b", map[string]interface{}{"aud": "api", "tenant_id": "b"}, "b"},
+		{"access token without tenant", map[string]interface{}{"aud": "api"}, ""},
+		{"access token of another tenant", map[string]interface{}{"aud": "api", "tenant_id": "c"}, ""},
+		{"id token of a", map[string]interface{}{"aud": "client-a"}, "a"},
+		{"id token of b", map[string]interface{}{"aud": "client-b"}, "b"},
+		{"id token of a naming b", map[string]interface{}{"aud": "client-a", "tenant_id": "b"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := iss.sign(t, tt.claims)
+			for _, tenantID := range []string{"a", "b"} {
+				p, err := r.Authenticate(context.Background(), token, tenantID)
+				if tenantID == tt.owner {
+					if err != nil {
+						t.Errorf("tenant %s rejected its token: %v", tenantID, err)
+					} else if p.TenantID != tenantID {
+						t.Errorf("tenant %s: principal of tenant %q", tenantID, p.TenantID)
+					}
+					continue
+				}
+				if err == nil {
+					t.Errorf("tenant %s accepted a token of tenant %q", tenantID, tt.owner)
+				}
+			}
+
+			// Without a tenant the owner is found by the token alone
+			_, _, owner, err := r.Verify(context.Background(), token, "")
+			switch {
+			case tt.owner == "" && err == nil:
+				t.Errorf("token without owner verified for tenant %s", owner.ID)
+			case tt.owner != "" && (err != nil || owner.ID != tt.owner):
+				t.Errorf("verified without a tenant: owner %v, err %v, want %s", owner, err, tt.owner)
+			}
+		})
+	}
+}
+
+func TestSharedIssuerAndClient(t *testing.T) {
+	iss := newIssuer(t)
+	r, err := New("a", iss.tenant(t, "a", "client"), iss.tenant(t, "b", "client"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With one client ID tokens can only be told apart by the tenant claim
+	token := iss.sign(t, map[string]interface{}{"aud": "client"})
+	for _, tenantID := range []string{"a", "b"} {
+		if _, err := r.Authenticate(context.Background(), token, tenantID); !errors.Is(err, ErrWrongTenant) {
+			t.Errorf("tenant %s: err = %v, want ErrWrongTenant", tenantID, err)
+		}
+	}
+
+	token = iss.sign(t, map[string]interface{}{"aud": "client", "tenant_id": "b"})
+	if _, err := r.Authenticate(context.Background(), token, "b"); err != nil {
+		t.Errorf("tenant b rejected its token: %v", err)
+	}
+	if _, err := r.Authenticate(context.Background(), token, "a"); !errors.Is(err, ErrWrongTenant) {
+		t.Errorf("tenant a: err = %v, want ErrWrongTenant", err)
+	}
+}
+
+func TestOwnIssuerNeedsNoTenantClaim(t *testing.T) {
+	a, b := newIssuer(t), newIssuer(t)
+	r, err := New("a", a.tenant(t, "a", "client", "api"), b.tenant(t, "b", "client", "api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := a.sign(t, map[string]interface{}{"aud": "api"})
+	if _, err := r.Authenticate(context.Background(), token, "a"); err != nil {
+		t.Errorf("tenant a rejected its token: %v", err)
+	}
+	if _, err := r.Authenticate(context.Background(), token, "b"); !errors.Is(err, ErrWrongTenant) {
+		t.Errorf("tenant b: err = %v, want ErrWrongTenant", err)
+	}
+}
@@ -0,0 +1,75 @@
+package backchannel
+
+import (
+	"log"
+	"net/http"
+
+	"authentication/src/platform/middleware"
+	"authentication/src/platform/revocation"
+	"authentication/src/platform/session"
+	"authentication/src/platform/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler receives OpenID Connect Back-Channel Logout requests from the
+// tenant's provider. The sessions named by the logout token are ended and
+// their tokens revoked. revocations also remembers used logout tokens.
+func Handler(tenants *tenant.Registry, sessions *session.Manager, revocations revocation.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Cache-Control", "no-store")
+		t := middleware.GetTenant(ctx)
+
+		raw := ctx.PostForm("logout_token")
+		if raw == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "logout_token is required"})
+			return
+		}
+
+		token, err := t.Auth.VerifyLogoutToken(ctx.Request.Context(), raw)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+			return
+		}
+
+		// Each logout token may only be used once. It is only marked used once
+		// the logout went through, so the provider can retry failures.
+		key := "logout:" + token.ID
+		used, err := revocations.IsRevoked(ctx.Request.Context(), key)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": err.Error()})
+			return
+		}
+		if used {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "logout token was already used"})
+			return
+		}
+		ended, err := sessions.Terminate(ctx.Request.Context(), t.ID, token.Subject, token.SessionID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": err.Error()})
+			return
+		}
+
+		// Tokens of the ended sessions must not outlive them
+		for _, s := range ended {
+			if err := tenants.RevokeTokens(ctx.Request.Context(), t.ID, s.AccessToken, s.RefreshToken); err != nil {
+				log.Printf("backchannel logout: revoking tokens of %s: %v", s.Subject, err)
+			}
+		}
+
+		// A logout for the whole user also covers sessions we cannot enumerate,
+		// like cookie sessions and tokens handed to other clients
+		if token.SessionID == "" {
+			if _, err := tenants.RevokeAll(ctx.Request.Context(), t.ID, token.Subject); err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": err.Error()})
+				return
+			}
+		}
+
+		if err := revocations.Revoke(ctx.Request.Context(), key, token.ExpiresAt); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": err.Error()})
+			return
+		}
+		ctx.Status(http.StatusOK)
+	}
+}
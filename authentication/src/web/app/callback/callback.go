@@ -3,15 +3,42 @@ package callback
 import (
 	"encoding/json"
 	"html/template"
+	"log"
 	"net/http"
 
 	"authentication/src/platform/authenticator"
+	"authentication/src/platform/directory"
+	"authentication/src/platform/middleware"
+	"authentication/src/platform/session"
+	"authentication/src/platform/state"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
 )
 
-func Handler(auth *authenticator.Authenticator) gin.HandlerFunc {
+// Handler finishes the login and records the user in the directory. In
+// legacy mode the tokens are handed to the browser, otherwise they stay in
+// the session.
+func Handler(states *state.Store, cookie *state.Cookie, sessions *session.Manager, users *directory.Directory, legacy bool) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		t := middleware.GetTenant(ctx)
+
+		// Check the state against the cookie and the server side store
+		st := ctx.Query("state")
+		if err := cookie.Verify(ctx.Writer, ctx.Request, st); err != nil {
+			ctx.String(http.StatusBadRequest, "Invalid state: %v", err)
+			return
+		}
+		flow, err := states.Consume(st)
+		if err != nil {
+			ctx.String(http.StatusBadRequest, "Invalid state: %v", err)
+			return
+		}
+		if flow.TenantID != t.ID {
+			ctx.String(http.StatusBadRequest, "Invalid state: %v", state.ErrMismatch)
+			return
+		}
+
 		code := ctx.Query("code")
 		if code == "" {
 			ctx.Redirect(http.StatusTemporaryRedirect, "/")
@@ -19,14 +46,14 @@ func Handler(auth *authenticator.Authenticator) gin.HandlerFunc {
 		}
 
 		// Exchange code for token
-		token, err := auth.Exchange(ctx.Request.Context(), code)
+		token, err := t.Auth.Exchange(ctx.Request.Context(), code, oauth2.VerifierOption(flow.CodeVerifier))
 		if err != nil {
 			ctx.Redirect(http.StatusTemporaryRedirect, "/")
 			return
 		}
 
 		// Verify token
-		idToken, err := auth.VerifyIDToken(ctx.Request.Context(), token)
+		idToken, err := t.Auth.VerifyIDToken(ctx.Request.Context(), token)
 		if err != nil {
 			ctx.Redirect(http.StatusTemporaryRedirect, "/")
 			return
@@ -39,6 +66,37 @@ func Handler(auth *authenticator.Authenticator) gin.HandlerFunc {
 			return
 		}
 
+		// Keep the directory up to date, the login succeeds either way
+		info, err := t.Auth.UserInfo(ctx.Request.Context(), token)
+		if err != nil {
+			log.Printf("userinfo for %s failed: %v", idToken.Subject, err)
+		}
+		if _, err := users.Record(ctx.Request.Context(), t.ID, profile, info); err != nil {
+			log.Printf("failed to record user %s: %v", idToken.Subject, err)
+		}
+
+		// Log the browser in with a fresh session
+		sid, _ := profile["sid"].(string)
+		err = sessions.Start(ctx.Writer, ctx.Request, &session.Session{
+			TenantID:     t.ID,
+			Subject:      idToken.Subject,
+			SID:          sid,
+			Profile:      profile,
+			IDToken:      authenticator.IDToken(token),
+			AccessToken:  token.AccessToken,
+			RefreshToken: token.RefreshToken,
+			TokenExpiry:  token.Expiry,
+		})
+		if err != nil {
+			ctx.String(http.StatusInternalServerError, "Failed to start session: %v", err)
+			return
+		}
+
+		if !legacy {
+			ctx.Redirect(http.StatusSeeOther, flow.RedirectURL)
+			return
+		}
+
 		// Convert profile to JSON string
 		profileJSON, err := json.Marshal(profile)
 		if err != nil {
@@ -51,6 +109,7 @@ func Handler(auth *authenticator.Authenticator) gin.HandlerFunc {
 			"access_token": template.JS(template.JSEscapeString(token.AccessToken)),
 			"id_token":     template.JS(template.JSEscapeString(token.Extra("id_token").(string))),
 			"profile":      string(profileJSON),
+			"redirect_url": flow.RedirectURL,
 		})
 	}
 }
@@ -3,10 +3,14 @@ package home
 import (
 	"net/http"
 
+	"authentication/src/platform/middleware"
+
 	"github.com/gin-gonic/gin"
 )
 
 // Handler for our home page.
 func Handler(ctx *gin.Context) {
-	ctx.HTML(http.StatusOK, "home.html", nil)
+	ctx.HTML(http.StatusOK, "home.html", gin.H{
+		"login_url": middleware.TenantPath(ctx, "/login"),
+	})
 }
@@ -0,0 +1,88 @@
+package introspect
+
+import (
+	"net/http"
+	"strings"
+
+	"authentication/src/platform/clientauth"
+	"authentication/src/platform/middleware"
+	"authentication/src/platform/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler implements OAuth 2.0 Token Introspection (RFC 7662). Callers
+// authenticate as one of clients. Tokens are verified exactly like in the
+// VerifyToken RPC, for the request's tenant.
+func Handler(tenants *tenant.Registry, clients *clientauth.Clients) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Cache-Control", "no-store")
+
+		if _, err := clients.Authenticate(ctx.Request); err != nil {
+			ctx.Header("WWW-Authenticate", `Basic realm="introspect"`)
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client", "error_description": err.Error()})
+			return
+		}
+
+		token := ctx.PostForm("token")
+		if token == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "token is required"})
+			return
+		}
+
+		// token_type_hint is optional and we can tell the kinds apart ourselves
+		principal, err := tenants.Authenticate(ctx.Request.Context(), token, middleware.GetTenant(ctx).ID)
+		if err != nil {
+			ctx.JSON(http.StatusOK, gin.H{"active": false})
+			return
+		}
+
+		res := gin.H{
+			"active":         true,
+			"sub":            principal.Subject,
+			"tenant_id":      principal.TenantID,
+			"principal_type": principal.Type,
+			"token_type":     "Bearer",
+			"token_kind":     principal.Kind.String(),
+		}
+		// API keys may not expire and carry no issuer or audience
+		if !principal.ExpiresAt.IsZero() {
+			res["exp"] = principal.ExpiresAt.Unix()
+		}
+		if !principal.IssuedAt.IsZero() {
+			res["iat"] = principal.IssuedAt.Unix()
+		}
+		for _, claim := range []string{"iss", "aud"} {
+			if value, ok := principal.Claims[claim]; ok && value != nil {
+				res[claim] = value
+			}
+		}
+		if len(principal.Scopes) > 0 {
+			res["scope"] = strings.Join(principal.Scopes, " ")
+		}
+		if clientID := clientID(principal.Claims); clientID != "" {
+			res["client_id"] = clientID
+		}
+		if principal.Email != "" {
+			res["email"] = principal.Email
+		}
+		if len(principal.Roles) > 0 {
+			res["roles"] = principal.Roles
+		}
+		if len(principal.Permissions) > 0 {
+			res["permissions"] = principal.Permissions
+		}
+		ctx.JSON(http.StatusOK, res)
+	}
+}
+
+// clientID reads the client a token was issued to. Providers use client_id
+// (RFC 9068) or azp.
+func clientID(claims map[string]interface{}) string {
+	for _, name := range []string{"client_id", "azp"} {
+		if id, ok := claims[name].(string); ok && id != "" {
+			return id
+		}
+	}
+	return ""
+}
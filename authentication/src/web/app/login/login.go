@@ -1,34 +1,44 @@
 package login
 
 import (
-	"authentication/src/platform/authenticator"
-	"crypto/rand"
-	"encoding/base64"
 	"net/http"
 
+	"authentication/src/platform/middleware"
+	"authentication/src/platform/redirect"
+	"authentication/src/platform/state"
+
 	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
 )
 
-func Handler(auth *authenticator.Authenticator) gin.HandlerFunc {
+func Handler(states *state.Store, cookie *state.Cookie, redirects *redirect.AllowList) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		t := middleware.GetTenant(ctx)
+
+		// Only allow-listed destinations may be returned to after login
+		returnTo := middleware.TenantPath(ctx, "/user")
+		if target := ctx.Query("return_to"); target != "" {
+			checked, err := redirects.Check(t.ID, target)
+			if err != nil {
+				ctx.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			returnTo = checked
+		}
+
 		// Generate random state
-		state, err := generateRandomState()
+		st, err := state.Generate()
 		if err != nil {
 			ctx.String(http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		// Store state in query parameter instead of session
-		authURL := auth.AuthCodeURL(state)
-		ctx.Redirect(http.StatusTemporaryRedirect, authURL)
-	}
-}
+		// Remember the state and PKCE verifier on the server and bind the state to this browser
+		verifier := oauth2.GenerateVerifier()
+		authURL := t.Auth.AuthCodeURL(st, oauth2.S256ChallengeOption(verifier))
+		states.Save(st, state.Flow{TenantID: t.ID, AuthURL: authURL, CodeVerifier: verifier, RedirectURL: returnTo})
+		cookie.Set(ctx.Writer, ctx.Request, st)
 
-func generateRandomState() (string, error) {
-	b := make([]byte, 32)
-	_, err := rand.Read(b)
-	if err != nil {
-		return "", err
+		ctx.Redirect(http.StatusTemporaryRedirect, authURL)
 	}
-	return base64.StdEncoding.EncodeToString(b), nil
 }
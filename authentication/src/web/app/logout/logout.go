@@ -1,36 +1,124 @@
 package logout
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 	"net/url"
-	"os"
+
+	"authentication/src/platform/middleware"
+	"authentication/src/platform/redirect"
+	"authentication/src/platform/session"
+	"authentication/src/platform/state"
+	"authentication/src/platform/tenant"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Handler for our logout.
-func Handler(ctx *gin.Context) {
-	logoutUrl, err := url.Parse("https://" + os.Getenv("AUTH0_DOMAIN") + "/v2/logout")
-	if err != nil {
-		ctx.String(http.StatusInternalServerError, err.Error())
-		return
+// Handler for our logout. It ends the session, revokes its tokens and sends
+// the browser to the provider, which returns it to Callback. It is only
+// served for POST so cross-site links cannot log users out. Legacy clients
+// can post the tokens they hold as access_token and refresh_token.
+func Handler(tenants *tenant.Registry, states *state.Store, cookie *state.Cookie, redirects *redirect.AllowList, sessions *session.Manager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		t := middleware.GetTenant(ctx)
+
+		base, err := origin(t)
+		if err != nil {
+			ctx.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Only allow-listed destinations may be returned to after logout
+		returnTo := base
+		if target := ctx.Query("return_to"); target != "" {
+			checked, err := redirects.Check(t.ID, target)
+			if err != nil {
+				ctx.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			if returnTo, err = base.Parse(checked); err != nil {
+				ctx.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		idTokenHint := ctx.Query("id_token_hint")
+		accessToken, refreshToken := ctx.PostForm("access_token"), ctx.PostForm("refresh_token")
+		if s, err := sessions.Get(ctx.Writer, ctx.Request); err == nil && s.TenantID == t.ID {
+			if idTokenHint == "" {
+				idTokenHint = s.IDToken
+			}
+			accessToken, refreshToken = s.AccessToken, s.RefreshToken
+		}
+
+		// End our session and its tokens before the provider's session
+		if err := sessions.Destroy(ctx.Writer, ctx.Request); err != nil {
+			ctx.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := tenants.RevokeTokens(ctx.Request.Context(), t.ID, accessToken, refreshToken); err != nil {
+			log.Printf("logout: revoking tokens: %v", err)
+		}
+
+		// The provider returns to Callback with this state, bound to the browser
+		st, err := state.Generate()
+		if err != nil {
+			ctx.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		states.Save(st, state.Flow{TenantID: t.ID, RedirectURL: returnTo.String()})
+		cookie.Set(ctx.Writer, ctx.Request, st)
+
+		callbackURL, err := base.Parse(middleware.TenantPath(ctx, "/logout/callback"))
+		if err != nil {
+			ctx.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		logoutURL, err := t.Auth.LogoutURL(callbackURL.String(), idTokenHint, st)
+		if err != nil {
+			ctx.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		ctx.Redirect(http.StatusSeeOther, logoutURL)
 	}
+}
+
+// Callback is where the provider sends browsers after logout. The state
+// has to match the one Handler bound to the browser before it is sent on.
+func Callback(states *state.Store, cookie *state.Cookie) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		st := ctx.Query("state")
+		if err := cookie.Verify(ctx.Writer, ctx.Request, st); err != nil {
+			ctx.String(http.StatusBadRequest, "Invalid state: %v", err)
+			return
+		}
+		flow, err := states.Consume(st)
+		if err != nil {
+			ctx.String(http.StatusBadRequest, "Invalid state: %v", err)
+			return
+		}
+		if flow.TenantID != middleware.GetTenant(ctx).ID {
+			ctx.String(http.StatusBadRequest, "Invalid state: %v", state.ErrMismatch)
+			return
+		}
 
-	scheme := "http"
-	if ctx.Request.TLS != nil {
-		scheme = "https"
+		ctx.Redirect(http.StatusSeeOther, flow.RedirectURL)
 	}
+}
 
-	returnTo, err := url.Parse(scheme + "://" + ctx.Request.Host)
+// origin is where the tenant's callback URL is served. It is taken from
+// configuration, since behind a proxy neither the request's TLS state nor
+// its Host header can be trusted.
+func origin(t *tenant.Tenant) (*url.URL, error) {
+	callback, err := url.Parse(t.Auth.OAuth2().RedirectURL)
 	if err != nil {
-		ctx.String(http.StatusInternalServerError, err.Error())
-		return
+		return nil, err
 	}
-
-	parameters := url.Values{}
-	parameters.Add("returnTo", returnTo.String())
-	parameters.Add("client_id", os.Getenv("AUTH0_CLIENT_ID"))
-	logoutUrl.RawQuery = parameters.Encode()
-
-	ctx.Redirect(http.StatusTemporaryRedirect, logoutUrl.String())
+	if callback.Scheme == "" || callback.Host == "" {
+		return nil, fmt.Errorf("tenant %q has no absolute callback URL", t.ID)
+	}
+	return &url.URL{Scheme: callback.Scheme, Host: callback.Host}, nil
 }
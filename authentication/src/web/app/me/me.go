@@ -0,0 +1,34 @@
+package me
+
+import (
+	"net/http"
+
+	"authentication/src/platform/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler returns the caller verified by the auth middleware.
+func Handler(ctx *gin.Context) {
+	principal, ok := middleware.GetPrincipal(ctx)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	res := gin.H{
+		"sub":         principal.Subject,
+		"tenant_id":   principal.TenantID,
+		"type":        principal.Type,
+		"scopes":      principal.Scopes,
+		"roles":       principal.Roles,
+		"permissions": principal.Permissions,
+		"email":       principal.Email,
+		"token_kind":  principal.Kind.String(),
+		"claims":      principal.Claims,
+	}
+	if !principal.ExpiresAt.IsZero() {
+		res["expires_at"] = principal.ExpiresAt.Unix()
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,48 @@
+package me
+
+import (
+	"errors"
+	"net/http"
+
+	"authentication/src/platform/directory"
+	"authentication/src/platform/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type privacyRequest struct {
+	Pseudonymous bool `json:"pseudonymous"`
+}
+
+// Privacy lets the caller choose whether others see them under a pseudonym.
+func Privacy(users *directory.Directory) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal, ok := middleware.GetPrincipal(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		var req privacyRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		u, err := users.SetPseudonymous(ctx.Request.Context(), principal.TenantID, principal.Subject, req.Pseudonymous)
+		switch {
+		case errors.Is(err, directory.ErrNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Log in again to create your profile"})
+		case err != nil:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update privacy"})
+		default:
+			profile := u.Public()
+			ctx.JSON(http.StatusOK, gin.H{
+				"user_id":      profile.UserID,
+				"display_name": profile.DisplayName,
+				"picture":      profile.Picture,
+				"pseudonymous": profile.Pseudonymous,
+			})
+		}
+	}
+}
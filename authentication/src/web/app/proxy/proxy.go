@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"authentication/src/platform/authenticator"
+	"authentication/src/platform/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler forwards /api/proxy/:service/*path to the service's upstream with
+// the caller's access token attached. It has to run after AuthRequired.
+// Cookies and API keys are not passed on, so upstreams never see the session
+// or a long-lived secret; callers using keys reach them unauthenticated.
+func Handler(upstreams map[string]*url.URL) gin.HandlerFunc {
+	proxies := make(map[string]*httputil.ReverseProxy, len(upstreams))
+	for name, target := range upstreams {
+		target := target
+		proxies[name] = &httputil.ReverseProxy{
+			Rewrite: func(r *httputil.ProxyRequest) {
+				r.SetURL(target)
+				r.SetXForwarded()
+				r.Out.Header.Del("Cookie")
+				r.Out.Header.Del("X-Api-Key")
+			},
+		}
+	}
+
+	return func(ctx *gin.Context) {
+		proxy, ok := proxies[ctx.Param("service")]
+		if !ok {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "unknown service"})
+			return
+		}
+
+		req := ctx.Request.Clone(ctx.Request.Context())
+		req.URL.Path = "/" + strings.TrimPrefix(ctx.Param("path"), "/")
+		req.URL.RawPath = ""
+		req.Header.Del("Authorization")
+		if principal, ok := middleware.GetPrincipal(ctx); ok && principal.Kind == authenticator.KindAccessToken {
+			req.Header.Set("Authorization", "Bearer "+ctx.GetString("token"))
+		}
+
+		proxy.ServeHTTP(ctx.Writer, req)
+	}
+}
@@ -0,0 +1,61 @@
+package refresh
+
+import (
+	"errors"
+	"net/http"
+
+	"authentication/src/platform/authenticator"
+	"authentication/src/platform/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type request struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+}
+
+// Handler exchanges a refresh token for a new token set. It accepts a JSON
+// or form encoded body and answers in the shape of a token endpoint.
+func Handler(ctx *gin.Context) {
+	var req request
+	if err := ctx.ShouldBind(&req); err != nil || req.RefreshToken == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": "refresh_token is required",
+		})
+		return
+	}
+
+	token, err := middleware.GetTenant(ctx).Auth.Refresh(ctx.Request.Context(), req.RefreshToken)
+	if err != nil {
+		status, code := refreshError(err)
+		ctx.JSON(status, gin.H{"error": code, "error_description": err.Error()})
+		return
+	}
+
+	res := gin.H{
+		"access_token":  token.AccessToken,
+		"id_token":      authenticator.IDToken(token),
+		"refresh_token": token.RefreshToken,
+		"token_type":    token.Type(),
+	}
+	if !token.Expiry.IsZero() {
+		res["expires_at"] = token.Expiry.Unix()
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, res)
+}
+
+func refreshError(err error) (int, string) {
+	switch {
+	case errors.Is(err, authenticator.ErrRefreshRejected):
+		return http.StatusBadRequest, "invalid_grant"
+	case errors.Is(err, authenticator.ErrClientRejected):
+		return http.StatusBadRequest, "unauthorized_client"
+	case errors.Is(err, authenticator.ErrRateLimited):
+		return http.StatusTooManyRequests, "temporarily_unavailable"
+	default:
+		return http.StatusServiceUnavailable, "temporarily_unavailable"
+	}
+}
@@ -0,0 +1,48 @@
+package revoke
+
+import (
+	"net/http"
+
+	"authentication/src/platform/clientauth"
+	"authentication/src/platform/middleware"
+	"authentication/src/platform/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler implements OAuth 2.0 Token Revocation (RFC 7009) for the JWTs we
+// verify and for API keys and personal access tokens. Callers authenticate
+// as one of clients. Invalid and unknown tokens are answered with 200 like
+// revoked ones.
+func Handler(tenants *tenant.Registry, clients *clientauth.Clients) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Header("Cache-Control", "no-store")
+
+		if _, err := clients.Authenticate(ctx.Request); err != nil {
+			ctx.Header("WWW-Authenticate", `Basic realm="revoke"`)
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error":             "invalid_client",
+				"error_description": err.Error(),
+			})
+			return
+		}
+
+		token := ctx.PostForm("token")
+		if token == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error":             "invalid_request",
+				"error_description": "token is required",
+			})
+			return
+		}
+
+		if err := tenants.Revoke(ctx.Request.Context(), token, middleware.GetTenant(ctx).ID); err != nil {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":             "temporarily_unavailable",
+				"error_description": err.Error(),
+			})
+			return
+		}
+		ctx.Status(http.StatusOK)
+	}
+}
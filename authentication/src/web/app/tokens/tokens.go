@@ -0,0 +1,148 @@
+package tokens
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"authentication/src/platform/apikey"
+	"authentication/src/platform/authz"
+	"authentication/src/platform/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page renders the personal access token page. The page itself talks to the
+// JSON handlers below.
+func Page(ctx *gin.Context) {
+	ctx.HTML(http.StatusOK, "tokens.html", gin.H{
+		"api_url": middleware.TenantPath(ctx, "/api/tokens"),
+	})
+}
+
+// List returns the caller's personal access tokens and the permissions they
+// may hand to new ones. It has to run after AuthRequired.
+func List(keys *apikey.Keys, policy *authz.Policy) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal, ok := middleware.GetPrincipal(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		owned, err := keys.ListPersonal(ctx.Request.Context(), principal.TenantID, principal.Subject)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tokens"})
+			return
+		}
+
+		list := make([]gin.H, len(owned))
+		for i, key := range owned {
+			list[i] = token(key)
+		}
+		ctx.JSON(http.StatusOK, gin.H{
+			"tokens":      list,
+			"permissions": orEmpty(policy.Permissions(authz.SubjectOf(principal))),
+		})
+	}
+}
+
+type createRequest struct {
+	Name        string    `json:"name"`
+	Permissions []string  `json:"permissions"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Create issues a personal access token for the caller. The secret is only
+// part of this response.
+func Create(keys *apikey.Keys, policy *authz.Policy) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal, ok := middleware.GetPrincipal(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if !apikey.CanOwn(principal) {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "Only signed in users can create personal access tokens"})
+			return
+		}
+
+		var req createRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil || req.Name == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "A name and an expiry are required"})
+			return
+		}
+		if missing := policy.Missing(authz.SubjectOf(principal), req.Permissions); len(missing) > 0 {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "You do not hold " + strings.Join(missing, ", ")})
+			return
+		}
+
+		created, secret, err := keys.CreatePersonal(ctx.Request.Context(), apikey.Key{
+			TenantID:  principal.TenantID,
+			Name:      req.Name,
+			CreatedBy: principal.Subject,
+			Owner:     principal.Subject,
+			Scopes:    req.Permissions,
+			ExpiresAt: req.ExpiresAt,
+		})
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		body := token(created)
+		body["secret"] = secret
+		ctx.JSON(http.StatusCreated, body)
+	}
+}
+
+// Revoke disables one of the caller's personal access tokens.
+func Revoke(keys *apikey.Keys) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		principal, ok := middleware.GetPrincipal(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		key, err := keys.RevokePersonal(ctx.Request.Context(), principal.TenantID, principal.Subject, ctx.Param("id"))
+		switch {
+		case errors.Is(err, apikey.ErrNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "No such token"})
+		case err != nil:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke the token"})
+		default:
+			ctx.JSON(http.StatusOK, token(key))
+		}
+	}
+}
+
+func token(key *apikey.Key) gin.H {
+	return gin.H{
+		"id":           key.ID,
+		"name":         key.Name,
+		"display":      key.Display(),
+		"permissions":  orEmpty(key.Scopes),
+		"created_at":   unix(key.CreatedAt),
+		"expires_at":   unix(key.ExpiresAt),
+		"last_used_at": unix(key.LastUsedAt),
+		"revoked_at":   unix(key.RevokedAt),
+	}
+}
+
+// orEmpty makes nil lists encode as [] rather than null for the page.
+func orEmpty(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// unix is 0 for unset times.
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -3,10 +3,34 @@ package user
 import (
 	"net/http"
 
+	"authentication/src/platform/directory"
+	"authentication/src/platform/middleware"
+
 	"github.com/gin-gonic/gin"
 )
 
-func Handler(ctx *gin.Context) {
-	// The JWT middleware will have already verified the token
-	ctx.HTML(http.StatusOK, "user.html", nil)
+func Handler(users *directory.Directory) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// IsAuthenticated will have already loaded the session
+		s, ok := middleware.GetSession(ctx)
+		if !ok {
+			ctx.Redirect(http.StatusSeeOther, middleware.TenantPath(ctx, "/"))
+			return
+		}
+
+		// Users missing from the directory have not chosen a pseudonym
+		var pseudonymous bool
+		if u, _, err := users.Get(ctx.Request.Context(), s.TenantID, s.Subject); err == nil {
+			pseudonymous = u.Pseudonymous
+		}
+
+		ctx.HTML(http.StatusOK, "user.html", gin.H{
+			"name":         s.Profile["name"],
+			"picture":      s.Profile["picture"],
+			"pseudonymous": pseudonymous,
+			"logout_url":   middleware.TenantPath(ctx, "/logout"),
+			"tokens_url":   middleware.TenantPath(ctx, "/tokens"),
+			"privacy_url":  middleware.TenantPath(ctx, "/api/me/privacy"),
+		})
+	}
 }